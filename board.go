@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bbs/internal/storage"
+)
+
+// Page sizes for board listings and thread reading
+const (
+	threadsPerPage = 15
+	postsPerPage   = 10
+	newPostsLimit  = 20
+)
+
+func (c *Client) listBoards() {
+	boards, err := c.db.GetBoards(c.user.ID)
+	if err != nil {
+		c.write("Error loading boards.\n")
+		return
+	}
+	if len(boards) == 0 {
+		c.write("There are no message boards yet.\n")
+		return
+	}
+
+	width, _ := c.windowSize()
+	c.write("\033[36mMessage Boards:\033[0m\n")
+	c.write(c.separator("-", 70) + "\n")
+	for _, board := range boards {
+		unread := ""
+		if board.Unread > 0 {
+			unread = fmt.Sprintf(" \033[32m(%d new)\033[0m", board.Unread)
+		}
+		line := fmt.Sprintf("\033[33m%-16s\033[0m %3d threads %4d posts%s", board.Name, board.Threads, board.Posts, unread)
+		if board.Description != "" {
+			line += " - " + truncateText(board.Description, width-visibleLen(line)-3)
+		}
+		c.write(line + "\n")
+	}
+	c.write(c.separator("-", 70) + "\n")
+	c.write("Use '/board <name>' to list threads, '/new' to read everything unread.\n\n")
+}
+
+// showBoard lists one page of a board's threads, most recently active first.
+func (c *Client) showBoard(args []string) {
+	if len(args) == 0 {
+		c.write("Usage: /board <name> [page]\n")
+		return
+	}
+	board, err := c.db.GetBoard(args[0])
+	if err != nil {
+		c.write(fmt.Sprintf("Board '%s' not found.\n", args[0]))
+		return
+	}
+	page, ok := parsePage(args[1:])
+	if !ok {
+		c.write("Usage: /board <name> [page]\n")
+		return
+	}
+
+	threads, err := c.db.GetThreads(board.ID, c.user.ID, threadsPerPage+1, (page-1)*threadsPerPage)
+	if err != nil {
+		c.write("Error loading threads.\n")
+		return
+	}
+	more := len(threads) > threadsPerPage
+	if more {
+		threads = threads[:threadsPerPage]
+	}
+
+	c.write(fmt.Sprintf("\033[36m%s\033[0m - %s (page %d)\n", board.Name, board.Description, page))
+	c.write(c.separator("-", 70) + "\n")
+	if len(threads) == 0 {
+		c.write("No threads here yet.\n")
+	}
+	width, _ := c.windowSize()
+	for _, thread := range threads {
+		marker := " "
+		if thread.Unread > 0 {
+			marker = "\033[32m*\033[0m"
+		}
+		line := fmt.Sprintf("%s %4d  %-12s %3d posts  %s  ", marker, thread.ID,
+			truncateText(thread.Author, 12), thread.Posts, thread.LastPostAt.Format("2006-01-02"))
+		c.write(line + truncateText(thread.Subject, width-visibleLen(line)) + "\n")
+	}
+	c.write(c.separator("-", 70) + "\n")
+	if more {
+		c.write(fmt.Sprintf("More threads: board %s %d\n", board.Name, page+1))
+	}
+	c.write(fmt.Sprintf("* = unread. '/read <id>' opens a thread, '/post %s' starts one.\n\n", board.Name))
+}
+
+// readThread shows one page of a thread and marks it read that far. With
+// no page it starts at the first unread post.
+func (c *Client) readThread(args []string) {
+	if len(args) == 0 {
+		c.write("Usage: /read <thread id> [page]\n")
+		return
+	}
+	threadID, err := strconv.Atoi(args[0])
+	if err != nil {
+		c.write("Usage: /read <thread id> [page]\n")
+		return
+	}
+	thread, err := c.db.GetThread(threadID, c.user.ID)
+	if err != nil {
+		c.write(fmt.Sprintf("Thread %d not found.\n", threadID))
+		return
+	}
+
+	pages := (thread.Posts + postsPerPage - 1) / postsPerPage
+	page := 1
+	if len(args) > 1 {
+		var ok bool
+		if page, ok = parsePage(args[1:]); !ok {
+			c.write("Usage: /read <thread id> [page]\n")
+			return
+		}
+	} else if thread.Unread > 0 {
+		page = (thread.Posts-thread.Unread)/postsPerPage + 1
+	}
+	if page > pages {
+		c.write(fmt.Sprintf("Thread %d only has %d page(s).\n", threadID, pages))
+		return
+	}
+
+	posts, err := c.db.GetPosts(threadID, postsPerPage, (page-1)*postsPerPage)
+	if err != nil {
+		c.write("Error loading posts.\n")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[36m[%s] %s\033[0m (page %d of %d)\n", thread.BoardName, thread.Subject, page, pages))
+	for _, post := range posts {
+		c.writePost(post)
+	}
+	c.write(c.separator("=", 60) + "\n")
+	if page < pages {
+		c.write(fmt.Sprintf("Next page: read %d %d\n", threadID, page+1))
+	}
+	c.write(fmt.Sprintf("Reply with '/reply %d'.\n\n", threadID))
+
+	if len(posts) > 0 {
+		c.db.MarkThreadRead(c.user.ID, threadID, posts[len(posts)-1].ID)
+	}
+}
+
+func (c *Client) writePost(post storage.Post) {
+	width, _ := c.windowSize()
+	c.write(c.separator("-", 60) + "\n")
+	c.write(fmt.Sprintf("\033[33m%s\033[0m \033[90m#%d %s\033[0m\n", post.Author, post.ID, post.CreatedAt.Format("2006-01-02 15:04")))
+	c.write(wrapText(post.Body, width, 0) + "\n")
+}
+
+// postThread starts a new thread on a board.
+func (c *Client) postThread(args []string) {
+	if len(args) == 0 {
+		c.write("Usage: /post <board>\n")
+		return
+	}
+	board, err := c.db.GetBoard(args[0])
+	if err != nil {
+		c.write(fmt.Sprintf("Board '%s' not found.\n", args[0]))
+		return
+	}
+
+	c.write(fmt.Sprintf("New thread on %s\nSubject: ", board.Name))
+	if !c.scanner.Scan() {
+		return
+	}
+	subject := strings.TrimSpace(c.scanner.Text())
+	if subject == "" {
+		c.write("A thread needs a subject.\n")
+		return
+	}
+
+	body, ok := c.readText(maxTextLines)
+	if !ok {
+		return
+	}
+
+	threadID, err := c.db.CreateThread(board.ID, c.user.ID, subject, body)
+	if err != nil {
+		c.write("Failed to create thread.\n")
+		return
+	}
+	// Your own post isn't news to you
+	if thread, err := c.db.GetThread(threadID, c.user.ID); err == nil {
+		c.markAllRead(thread)
+	}
+	c.write(fmt.Sprintf("\033[32mThread %d created on %s.\033[0m\n", threadID, board.Name))
+}
+
+// replyThread adds a post to the end of a thread.
+func (c *Client) replyThread(args []string) {
+	if len(args) == 0 {
+		c.write("Usage: /reply <thread id>\n")
+		return
+	}
+	threadID, err := strconv.Atoi(args[0])
+	if err != nil {
+		c.write("Usage: /reply <thread id>\n")
+		return
+	}
+	thread, err := c.db.GetThread(threadID, c.user.ID)
+	if err != nil {
+		c.write(fmt.Sprintf("Thread %d not found.\n", threadID))
+		return
+	}
+
+	c.write(fmt.Sprintf("Reply to [%s] %s\n", thread.BoardName, thread.Subject))
+	body, ok := c.readText(maxTextLines)
+	if !ok {
+		return
+	}
+
+	postID, err := c.db.AddPost(threadID, c.user.ID, body)
+	if err != nil {
+		c.write("Failed to post reply.\n")
+		return
+	}
+	// Only skip our own post if everything before it was read already
+	if thread.Unread == 0 {
+		c.db.MarkThreadRead(c.user.ID, threadID, postID)
+	}
+	c.write(fmt.Sprintf("\033[32mReply posted to thread %d.\033[0m\n", threadID))
+}
+
+// markAllRead moves the read pointer to the thread's last post.
+func (c *Client) markAllRead(thread *storage.Thread) {
+	posts, err := c.db.GetPosts(thread.ID, 1, thread.Posts-1)
+	if err == nil && len(posts) == 1 {
+		c.db.MarkThreadRead(c.user.ID, thread.ID, posts[0].ID)
+	}
+}
+
+// showNewPosts shows unread posts across every board, oldest thread
+// first, and marks them read.
+func (c *Client) showNewPosts() {
+	posts, err := c.db.GetUnreadPosts(c.user.ID, newPostsLimit)
+	if err != nil {
+		c.write("Error loading new posts.\n")
+		return
+	}
+	if len(posts) == 0 {
+		c.write("No unread posts.\n")
+		return
+	}
+
+	threadID := 0
+	for _, post := range posts {
+		if post.ThreadID != threadID {
+			threadID = post.ThreadID
+			c.write(fmt.Sprintf("\n\033[36m[%s] %s\033[0m (thread %d)\n", post.BoardName, post.Subject, post.ThreadID))
+		}
+		c.writePost(post)
+		c.db.MarkThreadRead(c.user.ID, post.ThreadID, post.ID)
+	}
+	c.write(c.separator("=", 60) + "\n")
+
+	if remaining, err := c.db.CountUnreadPosts(c.user.ID); err == nil && remaining > 0 {
+		c.write(fmt.Sprintf("%d more unread post(s). Type '/new' to continue.\n\n", remaining))
+	} else {
+		c.write("You're all caught up.\n\n")
+	}
+}
+
+// parsePage reads an optional page number argument, defaulting to 1.
+func parsePage(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 1, true
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil || page < 1 {
+		return 0, false
+	}
+	return page, true
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// postThread starts a thread on board through the interactive editor.
+func postThread(c *testClient, board, subject string, body ...string) {
+	c.t.Helper()
+
+	c.send("/post " + board)
+	c.expect("Subject: ")
+	c.send(subject)
+	c.expect("End with a line containing only '.'")
+	for _, line := range body {
+		c.send(line)
+	}
+	c.send(".")
+	c.expect("created on " + board + ".")
+	c.expectPrompt()
+}
+
+func TestBoardPostAndRead(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	postThread(alice, "General", "Favourite editors", "vi, obviously.", "", "Fight me.")
+
+	bob.send("/boards")
+	out := bob.expectPrompt()
+	if !strings.Contains(out, "General") || !strings.Contains(out, "(1 new)") || !strings.Contains(out, "Announcements") {
+		t.Fatalf("boards: %q", out)
+	}
+
+	bob.send("/board general")
+	out = bob.expectPrompt()
+	if !strings.Contains(out, "*    1  alice") || !strings.Contains(out, "Favourite editors") {
+		t.Fatalf("board listing: %q", out)
+	}
+
+	bob.send("/read 1")
+	out = bob.expectPrompt()
+	if !strings.Contains(out, "[General] Favourite editors") || !strings.Contains(out, "vi, obviously.\n\nFight me.") {
+		t.Fatalf("read: %q", out)
+	}
+
+	bob.send("/reply 1")
+	bob.expect("Reply to [General] Favourite editors")
+	bob.send("Emacs.")
+	bob.send(".")
+	bob.expect("Reply posted to thread 1.")
+	bob.expectPrompt()
+
+	bob.send("/new")
+	bob.expect("No unread posts.")
+	bob.expectPrompt()
+
+	alice.send("/new")
+	out = alice.expectPrompt()
+	if !strings.Contains(out, "[General] Favourite editors") || !strings.Contains(out, "Emacs.") || strings.Contains(out, "vi, obviously.") {
+		t.Fatalf("new: %q", out)
+	}
+	alice.send("/new")
+	alice.expect("No unread posts.")
+}
+
+func TestBoardNewAcrossBoards(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	for i := 1; i <= newPostsLimit+3; i++ {
+		postThread(alice, "General", fmt.Sprintf("Thread %d", i), fmt.Sprintf("body %d", i))
+	}
+	postThread(alice, "Announcements", "Welcome", "Be nice.")
+
+	bob.send("/new")
+	out := bob.expect("more unread post(s). Type '/new' to continue.")
+	if !strings.Contains(out, "[Announcements] Welcome") || !strings.Contains(out, "body 1\n") {
+		t.Fatalf("first scan: %q", out)
+	}
+	bob.expectPrompt()
+
+	bob.send("/new")
+	out = bob.expect("You're all caught up.")
+	if !strings.Contains(out, fmt.Sprintf("body %d\n", newPostsLimit+3)) || strings.Contains(out, "Be nice.") {
+		t.Fatalf("second scan: %q", out)
+	}
+}
+
+func TestBoardPages(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+
+	postThread(alice, "General", "Counting", "post 1")
+	for i := 2; i <= postsPerPage+2; i++ {
+		alice.send("/reply 1")
+		alice.expect("End with a line containing only '.'")
+		alice.send(fmt.Sprintf("/post %d", i))
+		alice.send(".")
+		alice.expect("Reply posted to thread 1.")
+		alice.expectPrompt()
+	}
+
+	alice.send("/read 1")
+	out := alice.expect("Next page: read 1 2")
+	if !strings.Contains(out, "(page 1 of 2)") || strings.Contains(out, fmt.Sprintf("post %d\n", postsPerPage+1)) {
+		t.Errorf("first page: %q", out)
+	}
+	alice.expectPrompt()
+
+	alice.send("/read 1 2")
+	out = alice.expectPrompt()
+	if !strings.Contains(out, fmt.Sprintf("post %d\n", postsPerPage+2)) || strings.Contains(out, "post 1\n") {
+		t.Errorf("second page: %q", out)
+	}
+
+	alice.send("/read 1 3")
+	alice.expect("Thread 1 only has 2 page(s).")
+	alice.send("/read 99")
+	alice.expect("Thread 99 not found.")
+	alice.send("/post Nowhere")
+	alice.expect("Board 'Nowhere' not found.")
+}
@@ -4,53 +4,149 @@ import (
 	"bufio"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"bbs/internal/storage"
+
+	"golang.org/x/crypto/ssh"
 )
 
+// Terminal is the protocol layer between a Client and the network
+// (telnet, SSH, ...). Reads deliver plain "\n"-terminated lines.
+type Terminal interface {
+	net.Conn
+
+	// SuppressEcho stops the user's typing from being shown, for passwords
+	SuppressEcho(suppress bool)
+}
+
 type Client struct {
-	conn         net.Conn
-	user         *User
-	currentRoom  *ChatRoom
-	db           *Database
-	server       *BBSServer
+	conn          net.Conn
+	term          Terminal
+	telnet        *TelnetConn // nil unless the client came in over telnet
+	user          *storage.User
+	userRole      string // loaded before each command; see refreshRole
+	db            storage.Store
+	server        *BBSServer
 	authenticated bool
-	scanner      *bufio.Scanner
+	scanner       *bufio.Scanner
+
+	sizeMutex sync.Mutex
+	width     int
+	height    int
+
+	// currentRoom gets plain text input; subscriptions are rooms followed
+	// in the background. Only the client's own goroutine changes
+	// currentRoom, under roomMutex, and other goroutines read it under
+	// roomMutex. They leave changes for the owner in movedTo and
+	// topicChanges instead; see applyRoomChanges. subscriptions is only
+	// used under roomMutex.
+	roomMutex     sync.RWMutex
+	currentRoom   *storage.ChatRoom
+	subscriptions map[int]*storage.ChatRoom
+	movedTo       *storage.ChatRoom
+	topicChanges  map[int]storage.TopicChange
+
+	// Output waits in outbox for the writer goroutine; see outbox.go
+	outbox       chan string
+	writerDone   chan struct{}
+	hangUp       chan struct{} // closed to flush outbox and close the connection
+	hangUpOnce   sync.Once
+	overflowOnce sync.Once
+	pendingDrops atomic.Int64 // dropped since the last notice to the user
+	dropped      atomic.Int64 // dropped since connecting
+
+	// Kept by idleReader on the reading goroutine; see idle.go
+	connectedAt time.Time
+	lastInput   time.Time
+	lastProbe   time.Time
+	idleWarned  bool
+	away        atomic.Bool
+}
+
+// NewClient creates a client for a raw telnet connection.
+func NewClient(conn net.Conn, db storage.Store, server *BBSServer) *Client {
+	telnet := NewTelnetConn(conn)
+	client := NewTerminalClient(telnet, db, server)
+	client.telnet = telnet
+
+	// Ask the terminal to report its size now and on every resize
+	telnet.OnResize = client.setWindowSize
+	telnet.RequestRemoteOption(TelnetOptNAWS)
+
+	return client
 }
 
-func NewClient(conn net.Conn, db *Database, server *BBSServer) *Client {
-	return &Client{
-		conn:    conn,
-		db:      db,
-		server:  server,
-		scanner: bufio.NewScanner(conn),
+// NewTerminalClient creates a client on top of an already set up protocol
+// layer such as an SSH session.
+func NewTerminalClient(term Terminal, db storage.Store, server *BBSServer) *Client {
+	now := time.Now()
+	client := &Client{
+		conn:   term,
+		term:   term,
+		db:     db,
+		server: server,
+		width:  defaultTermWidth,
+		height: defaultTermHeight,
+
+		subscriptions: make(map[int]*storage.ChatRoom),
+
+		outbox:     make(chan string, server.config.Limits.SendQueue),
+		writerDone: make(chan struct{}),
+		hangUp:     make(chan struct{}),
+
+		connectedAt: now,
+		lastInput:   now,
+		lastProbe:   now,
 	}
+	client.scanner = bufio.NewScanner(idleReader{client})
+	return client
 }
 
 func (c *Client) Handle() {
+	c.startWriter()
 	defer func() {
 		if c.user != nil {
+			c.markRoomRead()
+			c.markSubscriptionsRead()
 			c.server.RemoveClient(c)
 		}
+		c.stopWriter()
 		c.conn.Close()
 	}()
 
 	// Display logo and welcome message
 	c.displayWelcome()
 
-	// Authentication loop
-	if !c.authenticate() {
+	// Authentication loop, unless the transport already identified the
+	// user (SSH public key)
+	if c.user != nil {
+		c.authenticated = true
+		c.db.UpdateLastSeen(c.user.ID)
+		c.write(fmt.Sprintf("\033[32mWelcome back, %s!\033[0m\n\n", c.user.Username))
+	} else if !c.authenticate() {
+		return
+	}
+	if c.refuseBanned() {
 		return
 	}
+	c.applyConfiguredRole()
+	c.refreshRole()
 
 	// Display MOTD
 	c.displayMOTD()
+	c.showMailNotice()
 
 	// Join default room
-	if room, err := c.db.GetChatRoom("General"); err == nil {
-		c.currentRoom = room
+	if room, err := c.db.GetChatRoom(c.server.config.Seed.DefaultRoom); err == nil {
+		c.setCurrentRoom(room)
 		c.write(fmt.Sprintf("\n\033[32mJoined chat room: %s\033[0m\n", room.Name))
-		c.displayRecentMessages()
+		c.displayTopic()
+		c.displayCatchUp()
 	}
 
 	// Add client to server
@@ -85,6 +181,10 @@ func (c *Client) authenticate() bool {
 				return true
 			}
 		case "r", "register":
+			if !c.server.config.Features.Registration {
+				c.write("\033[31mRegistration of new accounts is closed.\033[0m\n\n")
+				continue
+			}
 			if c.register() {
 				return true
 			}
@@ -104,10 +204,10 @@ func (c *Client) login() bool {
 	username := strings.TrimSpace(c.scanner.Text())
 
 	c.write("Password: ")
-	if !c.scanner.Scan() {
+	password, ok := c.readPassword()
+	if !ok {
 		return false
 	}
-	password := strings.TrimSpace(c.scanner.Text())
 
 	user, err := c.db.AuthenticateUser(username, password)
 	if err != nil {
@@ -128,19 +228,24 @@ func (c *Client) register() bool {
 	}
 	username := strings.TrimSpace(c.scanner.Text())
 
-	if len(username) < 3 {
-		c.write("\033[31mUsername must be at least 3 characters long.\033[0m\n\n")
+	limits := c.server.config.Limits
+	if len(username) < limits.MinUsernameLength {
+		c.write(fmt.Sprintf("\033[31mUsername must be at least %d characters long.\033[0m\n\n", limits.MinUsernameLength))
+		return false
+	}
+	if len(username) > limits.MaxUsernameLength {
+		c.write(fmt.Sprintf("\033[31mUsername must be at most %d characters long.\033[0m\n\n", limits.MaxUsernameLength))
 		return false
 	}
 
 	c.write("Choose a password: ")
-	if !c.scanner.Scan() {
+	password, ok := c.readPassword()
+	if !ok {
 		return false
 	}
-	password := strings.TrimSpace(c.scanner.Text())
 
-	if len(password) < 4 {
-		c.write("\033[31mPassword must be at least 4 characters long.\033[0m\n\n")
+	if len(password) < limits.MinPasswordLength {
+		c.write(fmt.Sprintf("\033[31mPassword must be at least %d characters long.\033[0m\n\n", limits.MinPasswordLength))
 		return false
 	}
 
@@ -162,13 +267,61 @@ func (c *Client) register() bool {
 	return true
 }
 
+// readPassword reads a line with the terminal's echo turned off
+func (c *Client) readPassword() (string, bool) {
+	c.term.SuppressEcho(true)
+	defer c.term.SuppressEcho(false)
+
+	if !c.scanner.Scan() {
+		return "", false
+	}
+	// The user's Enter was not echoed either
+	c.write("\n")
+	return strings.TrimSpace(c.scanner.Text()), true
+}
+
+// Longest mail body or board post accepted, in lines
+const maxTextLines = 200
+
+// readText reads a multi-line text ended by a line holding only ".", like
+// the admin tool's MOTD editor. It reports false, after telling the user,
+// if the text is empty or longer than maxLines.
+func (c *Client) readText(maxLines int) (string, bool) {
+	c.write("Enter your message. End with a line containing only '.'\n")
+
+	var lines []string
+	for {
+		c.write("> ")
+		if !c.scanner.Scan() {
+			return "", false
+		}
+		line := strings.TrimRight(c.scanner.Text(), " \t")
+		if line == "." {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	if text == "" {
+		c.write("Message cancelled (empty).\n")
+		return "", false
+	}
+	if len(lines) > maxLines {
+		c.write(fmt.Sprintf("Message too long (%d lines, limit is %d). Not sent.\n", len(lines), maxLines))
+		return "", false
+	}
+	return text, true
+}
+
 func (c *Client) displayMOTD() {
 	if motd, err := c.db.GetMOTD(); err == nil {
-		c.write("\033[36m" + strings.Repeat("=", 60) + "\033[0m\n")
+		width, _ := c.windowSize()
+		c.write("\033[36m" + c.separator("=", 60) + "\033[0m\n")
 		c.write("\033[36mMESSAGE OF THE DAY\033[0m\n")
-		c.write("\033[36m" + strings.Repeat("=", 60) + "\033[0m\n")
-		c.write(motd.Content + "\n")
-		c.write("\033[36m" + strings.Repeat("=", 60) + "\033[0m\n\n")
+		c.write("\033[36m" + c.separator("=", 60) + "\033[0m\n")
+		c.write(wrapText(motd.Content, width, 0) + "\n")
+		c.write("\033[36m" + c.separator("=", 60) + "\033[0m\n\n")
 	}
 }
 
@@ -177,31 +330,80 @@ func (c *Client) displayRecentMessages() {
 		return
 	}
 
-	messages, err := c.db.GetRecentMessages(c.currentRoom.ID, 10)
+	c.displayPins()
+	messages, err := c.db.GetRecentMessages(c.currentRoom.ID, c.server.config.Limits.HistorySize)
 	if err != nil || len(messages) == 0 {
 		c.write("No recent messages in this room.\n\n")
 		return
 	}
 
 	c.write(fmt.Sprintf("\033[35mRecent messages in %s:\033[0m\n", c.currentRoom.Name))
-	c.write(strings.Repeat("-", 40) + "\n")
-	
+	c.write(c.separator("-", 40) + "\n")
+
+	for _, msg := range messages {
+		c.writeMessage(msg)
+	}
+	c.write(c.separator("-", 40) + "\n\n")
+}
+
+// displayCatchUp shows what was said in the current room since the user
+// was last here, or the recent history on a first visit, and marks the
+// room read.
+func (c *Client) displayCatchUp() {
+	if c.currentRoom == nil {
+		return
+	}
+	defer c.markRoomRead()
+
+	lastRead, err := c.db.GetLastRead(c.user.ID, c.currentRoom.ID)
+	if err != nil {
+		c.displayRecentMessages()
+		return
+	}
+	c.displayPins()
+
+	messages, err := c.db.GetMessagesSince(c.currentRoom.ID, lastRead, c.server.config.Limits.CatchUpSize)
+	if err != nil || len(messages) == 0 {
+		c.write("No new messages since your last visit.\n\n")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[35mNew in %s since your last visit:\033[0m\n", c.currentRoom.Name))
+	if total, err := c.db.CountMessagesSince(c.currentRoom.ID, lastRead); err == nil && total > len(messages) {
+		c.write(fmt.Sprintf("(%d more, type 'history' to see all)\n", total-len(messages)))
+	}
+	c.write(c.separator("-", 40) + "\n")
+
 	for _, msg := range messages {
-		timestamp := msg.Timestamp.Format("15:04")
-		c.write(fmt.Sprintf("\033[90m[%s]\033[0m \033[33m%s:\033[0m %s\n", timestamp, msg.Username, msg.Content))
+		c.writeMessage(msg)
+	}
+	c.write(c.separator("-", 40) + "\n\n")
+}
+
+// markRoomRead records that the user has seen everything in the current
+// room so far.
+func (c *Client) markRoomRead() {
+	if c.currentRoom != nil {
+		c.db.MarkRoomRead(c.user.ID, c.currentRoom.ID)
 	}
-	c.write(strings.Repeat("-", 40) + "\n\n")
+}
+
+func (c *Client) writeMessage(msg storage.Message) {
+	timestamp := msg.Timestamp.Format("15:04")
+	c.writeWrapped(fmt.Sprintf("\033[90m[%s]\033[0m \033[33m%s:\033[0m %s%s\n", timestamp, msg.Username, msg.Content, editedMarker(msg)))
 }
 
 func (c *Client) commandLoop() {
 	c.write(fmt.Sprintf("\033[32mType 'help' for commands. Current room: %s\033[0m\n", c.currentRoom.Name))
-	
+
 	for {
-		c.write(fmt.Sprintf("\033[34m[%s]>\033[0m ", c.currentRoom.Name))
-		
+		c.applyRoomChanges()
+		c.write(c.prompt())
+
 		if !c.scanner.Scan() {
 			break
 		}
+		c.applyRoomChanges()
 
 		input := strings.TrimSpace(c.scanner.Text())
 		if input == "" {
@@ -214,6 +416,19 @@ func (c *Client) commandLoop() {
 	}
 }
 
+// bareCommands can be typed without a leading "/".
+var bareCommands = map[string]bool{
+	"help":    true,
+	"rooms":   true,
+	"join":    true,
+	"users":   true,
+	"msg":     true,
+	"history": true,
+	"motd":    true,
+	"quit":    true,
+	"exit":    true,
+}
+
 func (c *Client) handleCommand(input string) bool {
 	parts := strings.Fields(input)
 	if len(parts) == 0 {
@@ -223,6 +438,20 @@ func (c *Client) handleCommand(input string) bool {
 	command := strings.ToLower(parts[0])
 	args := parts[1:]
 
+	// Only the original commands work as bare words; the rest need a
+	// leading "/" so chat that starts with one of them stays chat
+	c.refreshRole()
+	if name := strings.TrimPrefix(command, "/"); name != command {
+		command = name
+	} else if !bareCommands[command] {
+		c.sendMessage(input)
+		return true
+	}
+	if !c.allowed(command) {
+		c.write(fmt.Sprintf("You don't have permission to use '%s'.\n", command))
+		return true
+	}
+
 	switch command {
 	case "help":
 		c.showHelp()
@@ -242,10 +471,88 @@ func (c *Client) handleCommand(input string) bool {
 		} else {
 			c.write("Usage: msg <your_message>\n")
 		}
+	case "tell", "w":
+		if len(args) > 1 {
+			c.sendDirectMessage(args[0], strings.Join(args[1:], " "))
+		} else {
+			c.write("Usage: /tell <user> <message>\n")
+		}
+	case "dms":
+		c.showDirectMessages(args)
+	case "mail":
+		c.handleMail(args)
+	case "boards":
+		c.listBoards()
+	case "board":
+		c.showBoard(args)
+	case "read":
+		c.readThread(args)
+	case "post":
+		c.postThread(args)
+	case "reply":
+		c.replyThread(args)
+	case "new":
+		c.showNewPosts()
+	case "create":
+		c.createRoom(args)
+	case "room":
+		c.manageRoom(args)
+	case "subscribe", "sub":
+		c.subscribe(args)
+	case "unsubscribe", "unsub":
+		c.unsubscribe(args)
+	case "topic":
+		c.handleTopic(args)
+	case "pins":
+		c.showPins()
+	case "pin":
+		c.pinMessage(args)
+	case "unpin":
+		c.unpinMessage(args)
+	case "invite":
+		c.invite(args)
+	case "uninvite":
+		c.uninvite(args)
+	case "members":
+		c.showMembers(args)
+	case "access":
+		c.setAccess(args)
 	case "history":
-		c.showHistory()
+		c.showHistory(args)
+	case "search":
+		c.searchMessages(strings.Join(args, " "))
+	case "edit":
+		c.editMessage(args)
+	case "delete":
+		c.deleteMessage(args)
+	case "revisions":
+		c.showRevisions(args)
+	case "kick":
+		c.kick(args)
+	case "mute":
+		c.mute(args)
+	case "unmute":
+		c.unmute(args)
+	case "ban":
+		c.ban(args)
+	case "unban":
+		c.unban(args)
+	case "sanctions":
+		c.showSanctions(args)
+	case "role":
+		c.setRole(args)
+	case "queues":
+		c.showQueues()
+	case "accounts":
+		c.listAccounts()
+	case "broadcast":
+		c.broadcast(strings.Join(args, " "))
+	case "setmotd":
+		c.setMOTD()
 	case "motd":
 		c.displayMOTD()
+	case "keys":
+		c.handleKeys(args, input)
 	case "quit", "exit":
 		c.write("Goodbye!\n")
 		return false
@@ -263,18 +570,81 @@ func (c *Client) showHelp() {
   help                 - Show this help message
   rooms                - List all available chat rooms
   join <room>          - Join a specific chat room
+  /subscribe [room]    - Also follow a room while in another (also /sub)
+  /unsubscribe <room>  - Stop following a room (also /unsub)
   msg <message>        - Send a message to current room
+  /tell <user> <text>  - Send a private message (also /w)
+  /dms [user] [page]   - List private conversations or read one
+  /mail                - Your inbox (/mail send <user>, read/reply/delete <id>)
+  /boards              - List message boards
+  /board <name> [page] - List the threads on a board
+  /read <id> [page]    - Read a thread
+  /post <board>        - Start a new thread
+  /reply <id>          - Reply to a thread
+  /new                 - Read unread posts on all boards
   users                - List users currently online
-  history              - Show recent message history
+  /topic [text]        - Show or change the room's topic ('/topic clear', '/topic history')
+  /pins                - Show the messages pinned in this room
+  /members [room]      - List who may enter a private or password room
+  /invite <user> [room]
+                       - Let someone into a room you own
+  /uninvite <user> [room]
+                       - Take someone off a room's member list
+  /access <public|private|password> [room]
+                       - Choose who may enter a room you own
+  /create room <name> [description]
+                       - Start a room of your own
+  /room describe <text>
+                       - Change the description of a room you own
+  /room transfer <user> [room]
+                       - Give a room you own to someone else
+  /room archive|unarchive [room]
+                       - Make a room you own read-only, or open it again
+  /room delete <room>  - Delete a room you own and all its messages
+  history [count]      - Page back through this room's messages
+                         (filters: before <id>, since/until <date> [hh:mm], by <user>)
+  /search <words>      - Find messages in any room ("a phrase", in:<room>, by:<user>)
+  /edit <id> <text>    - Change one of your recent messages (id or 'last')
+  /delete <id>         - Delete one of your recent messages (id or 'last')
   motd                 - Display message of the day
+  /keys                - Manage SSH keys (/keys add <key>, /keys del <id>)
   quit/exit            - Leave the BBS
 
 \033[36mQuick messaging:\033[0m
-  You can also just type your message directly without 'msg'
+  You can also just type your message directly without 'msg'.
+  Commands shown with a '/' need it; without one the line is chat.
 
 \033[36mNavigation:\033[0m
   Your current room is shown in the prompt: [RoomName]>
 `
+	if c.hasRole(storage.RoleModerator) {
+		help += `
+\033[36mModerator commands:\033[0m
+  /revisions <id>      - Show earlier versions of an edited or deleted message
+  /pin <id|last>       - Pin a message to the top of its room
+  /unpin <id>          - Take a pin down
+  /kick <user> [reason]
+                       - Disconnect someone
+  /mute <user> [time] [here] [reason]
+                       - Stop someone chatting, everywhere or in this room
+                         (time like 30m, 2h, 7d; permanent if left out)
+  /unmute <user>       - Lift a mute
+  /ban <user|ip> [time] [ip] [reason]
+                       - Keep someone out; 'ip' also bans their addresses
+  /unban <user|ip>     - Lift a ban
+  /sanctions [user]    - List active mutes and bans, or a user's record
+`
+	}
+	if c.hasRole(storage.RoleSysop) {
+		help += `
+\033[36mSysop commands:\033[0m
+  /role <user> <role>  - Make someone a user, moderator or sysop
+  /accounts            - List every registered account and its role
+  /queues              - Show how far behind each session's connection is
+  /broadcast <message> - Send a notice to everyone online
+  /setmotd             - Replace the message of the day
+`
+	}
 	c.write(help + "\n")
 }
 
@@ -285,23 +655,41 @@ func (c *Client) listRooms() {
 		return
 	}
 
+	// Unread counts are a nicety; list the rooms without them on error
+	unread, _ := c.db.GetUnreadCounts(c.user.ID)
+
+	width, _ := c.windowSize()
 	c.write("\033[36mAvailable Chat Rooms:\033[0m\n")
-	c.write(strings.Repeat("-", 50) + "\n")
-	
+	c.write(c.separator("-", 50) + "\n")
+
 	for _, room := range rooms {
+		if !c.canEnter(&room) {
+			continue
+		}
 		currentMarker := ""
 		if c.currentRoom != nil && room.ID == c.currentRoom.ID {
 			currentMarker = " \033[32m(current)\033[0m"
+		} else if c.subscribed(room.ID) {
+			currentMarker = " \033[32m(subscribed)\033[0m"
+		} else if n := unread[room.ID]; n > 0 {
+			currentMarker = fmt.Sprintf(" \033[32m(%d new)\033[0m", n)
 		}
+		if !room.ArchivedAt.IsZero() {
+			currentMarker = " \033[90m[archived]\033[0m" + currentMarker
+		}
+		if room.Access != storage.RoomPublic {
+			currentMarker = fmt.Sprintf(" \033[90m[%s]\033[0m", room.Access) + currentMarker
+		}
+		// Squeeze the description into what's left of the line
+		room.Description = truncateText(room.Description, width-visibleLen(room.Name)-len(" - ")-visibleLen(currentMarker))
 		c.write(fmt.Sprintf("\033[33m%s\033[0m - %s%s\n", room.Name, room.Description, currentMarker))
 	}
-	c.write(strings.Repeat("-", 50) + "\n\n")
+	c.write(c.separator("-", 50) + "\n\n")
 }
 
 func (c *Client) joinRoom(roomName string) {
-	room, err := c.db.GetChatRoom(roomName)
-	if err != nil {
-		c.write(fmt.Sprintf("Room '%s' not found.\n", roomName))
+	room, ok := c.enterableRoom(roomName)
+	if !ok {
 		return
 	}
 
@@ -310,24 +698,40 @@ func (c *Client) joinRoom(roomName string) {
 		return
 	}
 
-	c.currentRoom = room
+	c.markRoomRead()
+	// A subscribed room's messages have been shown all along
+	if c.subscribed(room.ID) {
+		c.roomMutex.Lock()
+		c.currentRoom, c.subscriptions[room.ID], c.movedTo = room, room, nil
+		c.roomMutex.Unlock()
+		c.write(fmt.Sprintf("\033[32mNow talking in: %s\033[0m\n", room.Name))
+		c.displayTopic()
+		c.displayPins()
+		return
+	}
+	c.setCurrentRoom(room)
 	c.write(fmt.Sprintf("\033[32mJoined room: %s\033[0m\n", room.Name))
-	c.displayRecentMessages()
+	c.displayTopic()
+	c.displayCatchUp()
 }
 
 func (c *Client) listUsers() {
 	users := c.server.GetOnlineUsers()
+	away := c.server.AwayUsers()
 	c.write(fmt.Sprintf("\033[36mOnline Users (%d):\033[0m\n", len(users)))
-	c.write(strings.Repeat("-", 30) + "\n")
-	
+	c.write(c.separator("-", 30) + "\n")
+
+	width, _ := c.windowSize()
 	for _, user := range users {
 		currentMarker := ""
 		if user == c.user.Username {
 			currentMarker = " \033[32m(you)\033[0m"
+		} else if away[user] {
+			currentMarker = " \033[90m(away)\033[0m"
 		}
-		c.write(fmt.Sprintf("\033[33m%s\033[0m%s\n", user, currentMarker))
+		c.write(fmt.Sprintf("\033[33m%s\033[0m%s\n", truncateText(user, width-visibleLen(currentMarker)), currentMarker))
 	}
-	c.write(strings.Repeat("-", 30) + "\n\n")
+	c.write(c.separator("-", 30) + "\n\n")
 }
 
 func (c *Client) sendMessage(content string) {
@@ -336,7 +740,24 @@ func (c *Client) sendMessage(content string) {
 		return
 	}
 
-	if err := c.db.AddMessage(c.currentRoom.ID, c.user.ID, c.user.Username, content); err != nil {
+	if max := c.server.config.Limits.MaxMessageLength; len(content) > max {
+		c.write(fmt.Sprintf("Message too long (%d characters, limit is %d).\n", len(content), max))
+		return
+	}
+
+	if c.leaveLostRoom() {
+		return
+	}
+
+	if mute, err := c.db.GetActiveMute(c.user.ID, c.currentRoom.ID); err == nil {
+		c.write(fmt.Sprintf("\033[31mYou are muted %s %s.\033[0m\n", sanctionScope(mute), sanctionExpiry(mute)))
+		return
+	}
+
+	if err := c.db.AddMessage(c.currentRoom.ID, c.user.ID, c.user.Username, content); err == storage.ErrRoomArchived {
+		c.write(fmt.Sprintf("%s is archived and read-only.\n", c.currentRoom.Name))
+		return
+	} else if err != nil {
 		c.write("Failed to send message.\n")
 		return
 	}
@@ -347,26 +768,126 @@ func (c *Client) sendMessage(content string) {
 	c.server.BroadcastToRoom(c.currentRoom.ID, message, c)
 }
 
-func (c *Client) showHistory() {
-	if c.currentRoom == nil {
-		c.write("You are not in a chat room.\n")
+// handleKeys manages the SSH public keys that log in as this user.
+// raw is the full command line so the key text survives untouched.
+func (c *Client) handleKeys(args []string, raw string) {
+	if len(args) == 0 || strings.ToLower(args[0]) == "list" {
+		keys, err := c.db.GetUserKeys(c.user.ID)
+		if err != nil {
+			c.write("Error loading SSH keys.\n")
+			return
+		}
+		if len(keys) == 0 {
+			c.write("No SSH keys registered. Use '/keys add <public key>' to add one.\n")
+			return
+		}
+
+		width, _ := c.windowSize()
+		c.write("\033[36mYour SSH Keys:\033[0m\n")
+		c.write(c.separator("-", 60) + "\n")
+		for _, key := range keys {
+			line := fmt.Sprintf("%d  %s %s", key.ID, key.Fingerprint, key.Comment)
+			c.write(truncateText(line, width) + "\n")
+		}
+		c.write(c.separator("-", 60) + "\n\n")
 		return
 	}
-	c.displayRecentMessages()
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		// Everything after "keys add" is the authorized_keys line
+		line := strings.TrimSpace(raw[strings.Index(strings.ToLower(raw), "add")+len("add"):])
+		key, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			c.write("That doesn't look like an SSH public key.\n")
+			return
+		}
+		fingerprint := ssh.FingerprintSHA256(key)
+		if err := c.db.AddUserKey(c.user.ID, fingerprint, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))), comment); err != nil {
+			c.write("Failed to add key (is it already registered?).\n")
+			return
+		}
+		c.write(fmt.Sprintf("\033[32mAdded SSH key %s\033[0m\n", fingerprint))
+	case "del", "delete", "remove":
+		if len(args) < 2 {
+			c.write("Usage: /keys del <id>\n")
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			c.write("Usage: /keys del <id>\n")
+			return
+		}
+		if err := c.db.DeleteUserKey(c.user.ID, id); err != nil {
+			c.write("No such key.\n")
+			return
+		}
+		c.write("SSH key removed.\n")
+	default:
+		c.write("Usage: /keys [list | add <public key> | del <id>]\n")
+	}
 }
 
+// write queues output from the client's own goroutine; other goroutines
+// use deliver.
 func (c *Client) write(message string) {
-	c.conn.Write([]byte(message))
+	c.enqueue(message)
+}
+
+// writeWrapped writes a chat-style message word-wrapped to the client's
+// terminal width.
+func (c *Client) writeWrapped(message string) {
+	width, _ := c.windowSize()
+	c.write(wrapText(message, width, chatIndent))
 }
 
-func (c *Client) GetUser() *User {
+// separator returns a horizontal rule of ch, max columns wide or the
+// terminal width if that is narrower.
+func (c *Client) separator(ch string, max int) string {
+	width, _ := c.windowSize()
+	if width < max {
+		max = width
+	}
+	return strings.Repeat(ch, max)
+}
+
+func (c *Client) setWindowSize(width, height int) {
+	c.sizeMutex.Lock()
+	defer c.sizeMutex.Unlock()
+
+	// Zero means the terminal doesn't know, keep the previous value
+	if width > 0 {
+		c.width = width
+	}
+	if height > 0 {
+		c.height = height
+	}
+}
+
+// windowSize returns the client's terminal width and height.
+func (c *Client) windowSize() (int, int) {
+	c.sizeMutex.Lock()
+	defer c.sizeMutex.Unlock()
+	return c.width, c.height
+}
+
+func (c *Client) GetUser() *storage.User {
 	return c.user
 }
 
-func (c *Client) GetCurrentRoom() *ChatRoom {
+func (c *Client) GetCurrentRoom() *storage.ChatRoom {
 	return c.currentRoom
 }
 
+// TelnetOption reports whether a telnet option has been negotiated on
+// either side of the connection.
+func (c *Client) TelnetOption(opt byte) bool {
+	if c.telnet == nil {
+		return false
+	}
+	return c.telnet.LocalOption(opt) || c.telnet.RemoteOption(opt)
+}
+
 func readLogo() ([]byte, error) {
 	// Try to read the logo file, fallback to simple text if not available
 	return []byte(`
@@ -378,4 +899,4 @@ func readLogo() ([]byte, error) {
 
         Enhanced Bulletin Board System v2.0
 `), nil
-}
\ No newline at end of file
+}
@@ -2,113 +2,88 @@ package main
 
 import (
 	"bufio"
-	"database/sql"
+	"flag"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"strings"
-	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"bbs/internal/storage"
 )
 
-// Database types and methods (copied from main package for admin tool)
-type Database struct {
-	db *sql.DB
-}
-
-type MOTD struct {
-	ID        int
-	Content   string
-	UpdatedAt time.Time
-	UpdatedBy string
-}
+// exitCode is what the tool exits with once its input runs out, so a
+// script feeding it commands can tell whether they all worked.
+var exitCode int
 
-type ChatRoom struct {
-	ID          int
-	Name        string
-	Description string
-	CreatedAt   time.Time
-}
-
-func NewDatabase() (*Database, error) {
-	db, err := sql.Open("sqlite3", "../../bbs.db")
-	if err != nil {
-		return nil, err
-	}
-	return &Database{db: db}, nil
+// fail reports a command that didn't do what was asked.
+func fail(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+	exitCode = 1
 }
 
-func (d *Database) GetMOTD() (*MOTD, error) {
-	var motd MOTD
-	err := d.db.QueryRow("SELECT id, content, updated_at, updated_by FROM motd ORDER BY updated_at DESC LIMIT 1").
-		Scan(&motd.ID, &motd.Content, &motd.UpdatedAt, &motd.UpdatedBy)
-	if err != nil {
-		return nil, err
+func main() {
+	driver := flag.String("driver", storage.DriverSQLite, "database driver: sqlite or postgres")
+	dbPath := flag.String("db", "bbs.db", "SQLite database path, or PostgreSQL connection string with --driver postgres")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [--driver sqlite|postgres] [--db path|dsn] [migrate status|dry-run|up]\n", os.Args[0])
+		flag.PrintDefaults()
 	}
-	return &motd, nil
-}
+	flag.Parse()
 
-func (d *Database) SetMOTD(content, updatedBy string) error {
-	_, err := d.db.Exec("INSERT INTO motd (content, updated_by) VALUES (?, ?)", content, updatedBy)
-	return err
-}
-
-func (d *Database) GetChatRooms() ([]ChatRoom, error) {
-	rows, err := d.db.Query("SELECT id, name, description, created_at FROM chat_rooms ORDER BY name")
+	// Open the server's database; refuses missing files and newer schemas
+	db, err := storage.Open(*driver, *dbPath)
 	if err != nil {
-		return nil, err
+		fmt.Printf("Failed to open database: %v\n", err)
+		fmt.Println("Pass the server's database with --db <path>.")
+		os.Exit(1)
 	}
-	defer rows.Close()
+	defer db.Close()
 
-	var rooms []ChatRoom
-	for rows.Next() {
-		var room ChatRoom
-		if err := rows.Scan(&room.ID, &room.Name, &room.Description, &room.CreatedAt); err != nil {
-			continue
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			flag.Usage()
+			os.Exit(2)
 		}
-		rooms = append(rooms, room)
+		if err := handleMigrate(db, args[1:]); err != nil {
+			fmt.Printf("Migration failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	return rooms, nil
-}
-
-func (d *Database) CreateChatRoom(name, description string) error {
-	_, err := d.db.Exec("INSERT OR IGNORE INTO chat_rooms (name, description) VALUES (?, ?)", name, description)
-	return err
-}
-
-func (d *Database) Close() error {
-	return d.db.Close()
-}
 
-func main() {
-	// Initialize database
-	db, err := NewDatabase()
+	// The interactive commands only know the current schema
+	pending, err := db.PendingMigrations()
 	if err != nil {
-		fmt.Printf("Failed to connect to database: %v\n", err)
-		fmt.Println("Make sure you run this from the BBS root directory and the database exists.")
-		return
+		fmt.Printf("Failed to check schema version: %v\n", err)
+		os.Exit(1)
+	}
+	if len(pending) > 0 {
+		fmt.Printf("Database needs %d migration(s). Run '%s migrate up' with the same --driver/--db first.\n",
+			len(pending), os.Args[0])
+		os.Exit(1)
 	}
-	defer db.Close()
 
 	scanner := bufio.NewScanner(os.Stdin)
-	
+
 	fmt.Println("=== BBS Admin Tool ===")
-	fmt.Println("Commands: motd, room, users, help, quit")
-	
+	fmt.Println("Commands: motd, room, board, search, revisions, users, role, help, quit")
+
+commands:
 	for {
 		fmt.Print("admin> ")
 		if !scanner.Scan() {
 			break
 		}
-		
+
 		input := strings.TrimSpace(scanner.Text())
 		if input == "" {
 			continue
 		}
-		
+
 		parts := strings.Fields(input)
 		command := strings.ToLower(parts[0])
-		
+
 		switch command {
 		case "help":
 			showAdminHelp()
@@ -116,36 +91,72 @@ func main() {
 			handleMOTD(db, scanner)
 		case "room":
 			handleRoom(db, scanner, parts[1:])
+		case "board":
+			handleBoard(db, scanner, parts[1:])
+		case "search":
+			handleSearch(db, parts[1:])
+		case "revisions":
+			handleRevisions(db, parts[1:])
 		case "users":
 			handleUsers(db)
+		case "role":
+			handleRole(db, parts[1:])
+		case "sanctions":
+			handleSanctions(db)
+		case "unban":
+			handleUnban(db, parts[1:])
 		case "quit", "exit":
 			fmt.Println("Goodbye!")
-			return
+			break commands
 		default:
-			fmt.Println("Unknown command. Type 'help' for available commands.")
+			fail("Unknown command. Type 'help' for available commands.")
 		}
 	}
+
+	if exitCode != 0 {
+		db.Close()
+		os.Exit(exitCode)
+	}
 }
 
 func showAdminHelp() {
 	help := `
 Available Admin Commands:
   motd        - Update the Message of the Day
-  room        - Manage chat rooms (room list, room create)
-  users       - List all registered users
+  room        - Manage chat rooms (room list, create, access, owner)
+  board       - Manage message boards (board list, board create)
+  search      - Message search index (search status, search rebuild)
+  revisions   - Show earlier versions of an edited or deleted message
+  users       - List all registered users and their roles
+  role        - Change a user's role (user, moderator or sysop)
+  sanctions   - List the mutes and bans in force
+  unban       - Lift every ban on a user or an IP address
   help        - Show this help message
   quit/exit   - Exit admin tool
 
+Schema migrations run from the command line:
+  admin --db bbs.db migrate status   - Show applied and pending migrations
+  admin --db bbs.db migrate dry-run  - Print the SQL that would run
+  admin --db bbs.db migrate up       - Apply pending migrations
+
 Examples:
   motd                    - Update MOTD interactively
   room list               - List all chat rooms
   room create             - Create a new chat room
+  room access Den private - Make Den members only
+  room owner Den alice    - Let alice manage Den's members
+  board list              - List all message boards
+  board create            - Create a new message board
+  search rebuild          - Rebuild the full-text index from the messages
+  revisions 42            - Show how message 42 was edited or deleted
   users                   - Show all registered users
+  role alice moderator    - Make alice a moderator
+  unban 192.0.2.7         - Let an address connect again
 `
 	fmt.Println(help)
 }
 
-func handleMOTD(db *Database, scanner *bufio.Scanner) {
+func handleMOTD(db storage.Store, scanner *bufio.Scanner) {
 	// Show current MOTD
 	if motd, err := db.GetMOTD(); err == nil {
 		fmt.Println("\nCurrent MOTD:")
@@ -154,119 +165,468 @@ func handleMOTD(db *Database, scanner *bufio.Scanner) {
 		fmt.Println("=" + strings.Repeat("=", 50))
 		fmt.Printf("Last updated: %s by %s\n\n", motd.UpdatedAt.Format("2006-01-02 15:04:05"), motd.UpdatedBy)
 	}
-	
+
 	fmt.Println("Enter new MOTD (type 'END' on a line by itself to finish):")
-	
+
 	var lines []string
 	for {
 		fmt.Print("> ")
 		if !scanner.Scan() {
 			return
 		}
-		
+
 		line := scanner.Text()
 		if line == "END" {
 			break
 		}
-		
+
 		lines = append(lines, line)
 	}
-	
+
 	if len(lines) == 0 {
 		fmt.Println("MOTD not updated (empty content).")
 		return
 	}
-	
+
 	newMOTD := strings.Join(lines, "\n")
-	
+
 	if err := db.SetMOTD(newMOTD, "Admin"); err != nil {
-		fmt.Printf("Failed to update MOTD: %v\n", err)
+		fail("Failed to update MOTD: %v", err)
 		return
 	}
-	
+
 	fmt.Println("MOTD updated successfully!")
 }
 
-func handleRoom(db *Database, scanner *bufio.Scanner, args []string) {
+func handleRoom(db storage.Store, scanner *bufio.Scanner, args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: room <list|create>")
+		fail("Usage: room <list|create|access|owner>")
 		return
 	}
-	
+
 	subcommand := strings.ToLower(args[0])
-	
+
 	switch subcommand {
 	case "list":
 		rooms, err := db.GetChatRooms()
 		if err != nil {
-			fmt.Printf("Failed to get chat rooms: %v\n", err)
+			fail("Failed to get chat rooms: %v", err)
 			return
 		}
-		
+
 		fmt.Println("\nChat Rooms:")
 		fmt.Println("=" + strings.Repeat("=", 60))
 		for _, room := range rooms {
 			fmt.Printf("ID: %d | Name: %s | Description: %s\n", room.ID, room.Name, room.Description)
+			owner := room.OwnerName
+			if owner == "" {
+				owner = "-"
+			}
+			fmt.Printf("Access: %s | Owner: %s\n", room.Access, owner)
+			if room.Topic != "" {
+				fmt.Printf("Topic: %s (set by %s)\n", room.Topic, room.TopicBy)
+			}
+			if !room.ArchivedAt.IsZero() {
+				fmt.Printf("Archived: %s\n", room.ArchivedAt.Format("2006-01-02 15:04:05"))
+			}
 			fmt.Printf("Created: %s\n", room.CreatedAt.Format("2006-01-02 15:04:05"))
 			fmt.Println(strings.Repeat("-", 60))
 		}
-		
+
 	case "create":
 		fmt.Print("Room name: ")
 		if !scanner.Scan() {
 			return
 		}
 		name := strings.TrimSpace(scanner.Text())
-		
-		if name == "" {
-			fmt.Println("Room name cannot be empty.")
+
+		// Same rule as "create room", so the room can be joined and subscribed to
+		if !storage.ValidRoomName(name) {
+			fail("Room names are 2 to %d letters, digits, '-' or '_'.", storage.MaxRoomNameLength)
+			return
+		}
+		// Creating a room that exists quietly does nothing, and names
+		// differing only in case would be too easy to confuse
+		if room, err := db.FindChatRoom(name); err == nil {
+			fail("Room '%s' already exists.", room.Name)
 			return
 		}
-		
+
 		fmt.Print("Room description: ")
 		if !scanner.Scan() {
 			return
 		}
 		description := strings.TrimSpace(scanner.Text())
-		
+
 		if description == "" {
 			description = "No description provided"
 		}
-		
+
 		if err := db.CreateChatRoom(name, description); err != nil {
-			fmt.Printf("Failed to create room: %v\n", err)
+			fail("Failed to create room: %v", err)
 			return
 		}
-		
+
 		fmt.Printf("Chat room '%s' created successfully!\n", name)
-		
+
+	case "access":
+		usage := fmt.Sprintf("Usage: room access <name> <%s>", strings.Join(storage.RoomAccesses, "|"))
+		if len(args) != 3 {
+			fail("%s", usage)
+			return
+		}
+		access := strings.ToLower(args[2])
+		if access != storage.RoomPublic && access != storage.RoomPrivate && access != storage.RoomPassword {
+			fail("%s", usage)
+			return
+		}
+		room, err := db.GetChatRoom(args[1])
+		if err != nil {
+			fail("No such room '%s'.", args[1])
+			return
+		}
+
+		var password string
+		if access == storage.RoomPassword {
+			fmt.Print("Room password: ")
+			if !scanner.Scan() {
+				return
+			}
+			if password = strings.TrimSpace(scanner.Text()); password == "" {
+				fail("Password cannot be empty.")
+				return
+			}
+		}
+
+		if err := db.SetRoomAccess(room.ID, access, password); err != nil {
+			fail("Failed to change access: %v", err)
+			return
+		}
+		fmt.Printf("%s is now %s.\n", room.Name, access)
+
+	case "owner":
+		if len(args) != 3 {
+			fail("Usage: room owner <name> <user|->")
+			return
+		}
+		room, err := db.GetChatRoom(args[1])
+		if err != nil {
+			fail("No such room '%s'.", args[1])
+			return
+		}
+
+		var ownerID int
+		if args[2] != "-" {
+			user, err := db.GetUserByName(args[2])
+			if err != nil {
+				fail("No such user '%s'.", args[2])
+				return
+			}
+			ownerID = user.ID
+		}
+
+		if err := db.SetRoomOwner(room.ID, ownerID); err != nil {
+			fail("Failed to change owner: %v", err)
+			return
+		}
+		if ownerID == 0 {
+			fmt.Printf("%s no longer has an owner.\n", room.Name)
+		} else {
+			fmt.Printf("%s now owns %s.\n", args[2], room.Name)
+		}
+
 	default:
-		fmt.Println("Usage: room <list|create>")
+		fail("Usage: room <list|create|access|owner>")
 	}
 }
 
-func handleUsers(db *Database) {
-	rows, err := db.db.Query("SELECT id, username, joined_at, last_seen FROM users ORDER BY username")
+func handleBoard(db storage.Store, scanner *bufio.Scanner, args []string) {
+	if len(args) == 0 {
+		fail("Usage: board <list|create>")
+		return
+	}
+
+	subcommand := strings.ToLower(args[0])
+
+	switch subcommand {
+	case "list":
+		boards, err := db.GetBoards(0)
+		if err != nil {
+			fail("Failed to get boards: %v", err)
+			return
+		}
+
+		fmt.Println("\nMessage Boards:")
+		fmt.Println("=" + strings.Repeat("=", 60))
+		for _, board := range boards {
+			fmt.Printf("ID: %d | Name: %s | Description: %s\n", board.ID, board.Name, board.Description)
+			fmt.Printf("Threads: %d | Posts: %d | Created: %s\n", board.Threads, board.Posts, board.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Println(strings.Repeat("-", 60))
+		}
+
+	case "create":
+		fmt.Print("Board name: ")
+		if !scanner.Scan() {
+			return
+		}
+		name := strings.TrimSpace(scanner.Text())
+
+		if name == "" || strings.ContainsAny(name, " \t") {
+			fail("Board name must be a single word.")
+			return
+		}
+		// Boards are looked up ignoring case, so "general" would clash too
+		if board, err := db.GetBoard(name); err == nil {
+			fail("Board '%s' already exists.", board.Name)
+			return
+		}
+
+		fmt.Print("Board description: ")
+		if !scanner.Scan() {
+			return
+		}
+		description := strings.TrimSpace(scanner.Text())
+
+		if err := db.CreateBoard(name, description); err != nil {
+			fail("Failed to create board: %v", err)
+			return
+		}
+
+		fmt.Printf("Board '%s' created successfully!\n", name)
+
+	default:
+		fail("Usage: board <list|create>")
+	}
+}
+
+func handleSearch(db storage.Store, args []string) {
+	if len(args) == 0 {
+		fail("Usage: search <status|rebuild>")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "status":
+		switch db.SearchMode() {
+		case storage.SearchFTS5:
+			fmt.Println("Messages are searched with an SQLite FTS5 index.")
+		case storage.SearchTSVector:
+			fmt.Println("Messages are searched with a PostgreSQL tsvector index.")
+		default:
+			fmt.Println("No full-text index: searches scan messages with LIKE. Build with -tags sqlite_fts5 to enable FTS5.")
+		}
+
+	case "rebuild":
+		if err := db.RebuildSearchIndex(); err != nil {
+			fail("Failed to rebuild search index: %v", err)
+			return
+		}
+		fmt.Println("Search index rebuilt successfully!")
+
+	default:
+		fail("Usage: search <status|rebuild>")
+	}
+}
+
+func handleRevisions(db storage.Store, args []string) {
+	if len(args) != 1 {
+		fail("Usage: revisions <message id>")
+		return
+	}
+	messageID, err := strconv.Atoi(args[0])
+	if err != nil {
+		fail("Usage: revisions <message id>")
+		return
+	}
+
+	revisions, err := db.GetRevisions(messageID)
+	if err != nil {
+		fail("Failed to get revisions: %v", err)
+		return
+	}
+	if len(revisions) == 0 {
+		fmt.Printf("Message %d has never been edited or deleted.\n", messageID)
+		return
+	}
+
+	fmt.Printf("\nRevisions of message %d:\n", messageID)
+	fmt.Println("=" + strings.Repeat("=", 60))
+	for _, r := range revisions {
+		fmt.Printf("Before the %s at %s:\n", r.Action, r.CreatedAt.Format("2006-01-02 15:04:05"))
+		fmt.Println(r.Content)
+		fmt.Println(strings.Repeat("-", 60))
+	}
+	if msg, err := db.GetMessage(messageID); err == nil {
+		fmt.Printf("Now (%s): %s\n", msg.Username, msg.Content)
+	} else {
+		fmt.Println("Now: deleted")
+	}
+}
+
+func handleUsers(db storage.Store) {
+	users, err := db.ListUsers()
 	if err != nil {
-		fmt.Printf("Failed to get users: %v\n", err)
+		fail("Failed to get users: %v", err)
 		return
 	}
-	defer rows.Close()
-	
+
 	fmt.Println("\nRegistered Users:")
-	fmt.Println("=" + strings.Repeat("=", 80))
-	fmt.Printf("%-5s | %-20s | %-20s | %-20s\n", "ID", "Username", "Joined", "Last Seen")
-	fmt.Println(strings.Repeat("-", 80))
-	
-	for rows.Next() {
-		var id int
-		var username string
-		var joinedAt, lastSeen string
-		
-		if err := rows.Scan(&id, &username, &joinedAt, &lastSeen); err != nil {
-			continue
+	fmt.Println("=" + strings.Repeat("=", 93))
+	fmt.Printf("%-5s | %-20s | %-10s | %-20s | %-20s\n", "ID", "Username", "Role", "Joined", "Last Seen")
+	fmt.Println(strings.Repeat("-", 93))
+
+	for _, user := range users {
+		fmt.Printf("%-5d | %-20s | %-10s | %-20s | %-20s\n", user.ID, user.Username, user.Role,
+			user.JoinedAt.Format("2006-01-02 15:04:05"), user.LastSeen.Format("2006-01-02 15:04:05"))
+	}
+}
+
+func handleRole(db storage.Store, args []string) {
+	usage := fmt.Sprintf("Usage: role <user> <%s>", strings.Join(storage.Roles, "|"))
+	if len(args) != 2 {
+		fail("%s", usage)
+		return
+	}
+	role := strings.ToLower(args[1])
+	if storage.RoleRank(role) < 0 {
+		fail("%s", usage)
+		return
+	}
+
+	user, err := db.GetUserByName(args[0])
+	if err != nil {
+		fail("No such user '%s'.", args[0])
+		return
+	}
+	if err := db.SetUserRole(user.ID, role); err != nil {
+		fail("Failed to change role: %v", err)
+		return
+	}
+	fmt.Printf("%s is now a %s.\n", user.Username, role)
+}
+
+func handleSanctions(db storage.Store) {
+	sanctions, err := db.GetActiveSanctions()
+	if err != nil {
+		fail("Failed to get sanctions: %v", err)
+		return
+	}
+	if len(sanctions) == 0 {
+		fmt.Println("No mutes or bans in force.")
+		return
+	}
+
+	fmt.Println("\nActive Mutes and Bans:")
+	fmt.Println("=" + strings.Repeat("=", 93))
+	fmt.Printf("%-5s | %-4s | %-20s | %-15s | %-16s | %-10s | %s\n", "ID", "Kind", "Target", "Room", "Expires", "By", "Reason")
+	fmt.Println(strings.Repeat("-", 93))
+
+	for _, s := range sanctions {
+		target := s.Username
+		if s.IP != "" {
+			target = strings.TrimSpace(target + " " + s.IP)
+		}
+		room := "-"
+		if s.Kind == storage.SanctionMute {
+			room = "all"
+			if s.RoomName != "" {
+				room = s.RoomName
+			}
+		}
+		expires := "never"
+		if !s.ExpiresAt.IsZero() {
+			expires = s.ExpiresAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-5d | %-4s | %-20s | %-15s | %-16s | %-10s | %s\n", s.ID, s.Kind, target, room, expires, s.IssuerName, s.Reason)
+	}
+}
+
+func handleUnban(db storage.Store, args []string) {
+	if len(args) != 1 {
+		fail("Usage: unban <user|ip>")
+		return
+	}
+
+	var userID int
+	var ip string
+	if parsed := net.ParseIP(args[0]); parsed != nil {
+		ip = parsed.String()
+	} else {
+		user, err := db.GetUserByName(args[0])
+		if err != nil {
+			fail("No such user '%s'.", args[0])
+			return
+		}
+		userID = user.ID
+	}
+
+	n, err := db.LiftSanctions(storage.SanctionBan, userID, ip, 0)
+	if err != nil {
+		fail("Failed to unban: %v", err)
+		return
+	}
+	if n == 0 {
+		fmt.Printf("%s isn't banned.\n", args[0])
+		return
+	}
+	fmt.Printf("%s is no longer banned.\n", args[0])
+}
+
+// handleMigrate runs "migrate status", "migrate dry-run" or "migrate up".
+func handleMigrate(db storage.Store, args []string) error {
+	subcommand := "status"
+	if len(args) > 0 {
+		subcommand = strings.ToLower(args[0])
+	}
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		return err
+	}
+	version, err := db.Version()
+	if err != nil {
+		return err
+	}
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "status":
+		fmt.Printf("Schema version: %d (this build: %d)\n", version, storage.SchemaVersion)
+		for _, migration := range migrations {
+			state := "applied"
+			if migration.Version > version {
+				state = "pending"
+			}
+			fmt.Printf("  %04d %-30s %s\n", migration.Version, migration.Name, state)
+		}
+
+	case "dry-run":
+		if len(pending) == 0 {
+			fmt.Println("Database is up to date.")
+			return nil
+		}
+		for _, migration := range pending {
+			fmt.Printf("-- %04d_%s\n%s\n", migration.Version, migration.Name, strings.TrimSpace(migration.SQL))
 		}
-		
-		fmt.Printf("%-5d | %-20s | %-20s | %-20s\n", id, username, joinedAt[:19], lastSeen[:19])
+
+	case "up":
+		applied, err := db.Migrate()
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date.")
+		}
+
+	default:
+		return fmt.Errorf("unknown subcommand %q (want status, dry-run or up)", subcommand)
 	}
-}
\ No newline at end of file
+
+	return nil
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"bbs/internal/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config file read when -config isn't given, if it exists
+const defaultConfigPath = "bbs.yaml"
+
+// Config holds every server setting. It is filled from built-in defaults,
+// then the YAML config file, then BBS_* environment variables and finally
+// command-line flags, each overriding the previous.
+type Config struct {
+	Listen   ListenConfig   `yaml:"listen"`
+	TLS      TLSConfig      `yaml:"tls"`
+	SSH      SSHConfig      `yaml:"ssh"`
+	Web      WebConfig      `yaml:"web"`
+	Database DatabaseConfig `yaml:"database"`
+	Seed     SeedConfig     `yaml:"seed"`
+	Limits   LimitsConfig   `yaml:"limits"`
+	Timeouts TimeoutsConfig `yaml:"timeouts"`
+	Features FeaturesConfig `yaml:"features"`
+	Roles    RolesConfig    `yaml:"roles"`
+}
+
+// ListenConfig holds the address of each front-end; empty disables it.
+type ListenConfig struct {
+	Telnet string `yaml:"telnet"`
+	TLS    string `yaml:"tls"`
+	SSH    string `yaml:"ssh"`
+	Web    string `yaml:"web"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert"`
+	KeyFile  string `yaml:"key"`
+}
+
+type SSHConfig struct {
+	HostKey string `yaml:"host_key"`
+}
+
+// WebConfig decides which pages may open the browser gateway's WebSocket.
+// The BBS's own page always may; so may the origins listed here, such as
+// "https://bbs.example.com", or any page at all given "*".
+type WebConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// DatabaseConfig selects the storage backend: an SQLite file at Path, a
+// PostgreSQL server at DSN, or a memory database discarded on exit.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"`
+	Path   string `yaml:"path"`
+	DSN    string `yaml:"dsn"`
+}
+
+// Source returns the path or connection string for the configured driver.
+func (d DatabaseConfig) Source() string {
+	if d.Driver == storage.DriverPostgres {
+		return d.DSN
+	}
+	return d.Path
+}
+
+// SeedConfig is the data created in a fresh database. Rooms and boards are
+// also created in existing databases if missing.
+type SeedConfig struct {
+	Rooms       []RoomConfig  `yaml:"rooms"`
+	DefaultRoom string        `yaml:"default_room"`
+	Boards      []BoardConfig `yaml:"boards"`
+	MOTD        string        `yaml:"motd"`
+}
+
+type RoomConfig struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+type BoardConfig struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+type LimitsConfig struct {
+	MinUsernameLength int `yaml:"min_username_length"`
+	MaxUsernameLength int `yaml:"max_username_length"`
+	MinPasswordLength int `yaml:"min_password_length"`
+	MaxMessageLength  int `yaml:"max_message_length"`
+	HistorySize       int `yaml:"history_size"`
+	CatchUpSize       int `yaml:"catch_up_size"`
+	EditWindow        int `yaml:"edit_window"`     // minutes; 0 means no limit
+	MaxConnections    int `yaml:"max_connections"` // 0 means unlimited
+	RoomIdleDays      int `yaml:"room_idle_days"`  // 0 means never archive
+
+	// Messages waiting to be written to a slow client, and what to do
+	// when there are more
+	SendQueue    int    `yaml:"send_queue"`
+	SendOverflow string `yaml:"send_overflow"`
+}
+
+// TimeoutsConfig decides what happens to quiet connections. Zero turns
+// each off.
+type TimeoutsConfig struct {
+	Login     time.Duration `yaml:"login"`     // to finish logging in
+	Away      time.Duration `yaml:"away"`      // idle before shown as away
+	Idle      time.Duration `yaml:"idle"`      // idle before logged out
+	Keepalive time.Duration `yaml:"keepalive"` // between TCP keepalive and telnet NOP probes
+}
+
+// RolesConfig names accounts that are given a role whenever they log in,
+// whatever the database says.
+type RolesConfig struct {
+	Sysops []string `yaml:"sysops"`
+
+	// CreateRooms is the least trusted role allowed to create rooms
+	CreateRooms string `yaml:"create_rooms"`
+}
+
+type FeaturesConfig struct {
+	Registration bool `yaml:"registration"`
+	SSHKeyLogin  bool `yaml:"ssh_key_login"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Listen: ListenConfig{
+			Telnet: ":3003",
+		},
+		TLS: TLSConfig{
+			CertFile: "bbs.crt",
+			KeyFile:  "bbs.key",
+		},
+		SSH: SSHConfig{
+			HostKey: "ssh_host_ed25519_key",
+		},
+		Database: DatabaseConfig{
+			Driver: storage.DriverSQLite,
+			Path:   "bbs.db",
+		},
+		Seed: SeedConfig{
+			Rooms: []RoomConfig{
+				{"General", "General discussion for all users"},
+				{"Tech", "Technology and programming discussions"},
+				{"Gaming", "Video games and gaming culture"},
+				{"Random", "Random topics and casual chat"},
+			},
+			DefaultRoom: "General",
+			Boards: []BoardConfig{
+				{"Announcements", "News from the sysop"},
+				{"General", "Anything goes"},
+			},
+			MOTD: `Welcome to the Enhanced BBS!
+
+Features:
+- Multiple chat rooms for different topics
+- Real-time messaging with other users
+- User registration and authentication
+- Message history and user tracking
+
+Commands:
+- 'help' - Show available commands
+- 'rooms' - List all chat rooms
+- 'join <room>' - Join a chat room
+- 'msg <message>' - Send message to current room
+- 'users' - List online users
+- 'quit' - Exit the BBS
+
+Enjoy your stay!`,
+		},
+		Limits: LimitsConfig{
+			MinUsernameLength: 3,
+			MaxUsernameLength: 20,
+			MinPasswordLength: 4,
+			MaxMessageLength:  1000,
+			HistorySize:       10,
+			CatchUpSize:       50,
+			EditWindow:        15,
+			RoomIdleDays:      30,
+			SendQueue:         256,
+			SendOverflow:      OverflowDropOldest,
+		},
+		Timeouts: TimeoutsConfig{
+			Login:     2 * time.Minute,
+			Away:      10 * time.Minute,
+			Idle:      time.Hour,
+			Keepalive: time.Minute,
+		},
+		Features: FeaturesConfig{
+			Registration: true,
+			SSHKeyLogin:  true,
+		},
+		Roles: RolesConfig{
+			CreateRooms: storage.RoleUser,
+		},
+	}
+}
+
+// LoadConfig builds the configuration from defaults, the config file,
+// environment and command-line args (without the program name).
+func LoadConfig(args []string) (*Config, error) {
+	config := DefaultConfig()
+	settings := config.settings()
+
+	flags := flag.NewFlagSet("bbs", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to YAML config file (default "+defaultConfigPath+" if present)")
+	flagValues := make(map[string]*flagValue)
+	for _, setting := range settings {
+		value := &flagValue{isBool: setting.isBool}
+		flags.Var(value, setting.flag, setting.usage)
+		flagValues[setting.flag] = value
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *configPath
+	if path == "" {
+		path = os.Getenv("BBS_CONFIG")
+	}
+	if path == "" {
+		if _, err := os.Stat(defaultConfigPath); err == nil {
+			path = defaultConfigPath
+		}
+	}
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, setting := range settings {
+		if value, ok := os.LookupEnv(setting.env); ok {
+			if err := setting.set(value); err != nil {
+				return nil, fmt.Errorf("%s: %v", setting.env, err)
+			}
+		}
+	}
+
+	// Only flags given explicitly override
+	for _, setting := range settings {
+		if value := flagValues[setting.flag]; value.given {
+			if err := setting.set(value.value); err != nil {
+				return nil, fmt.Errorf("-%s: %v", setting.flag, err)
+			}
+		}
+	}
+	config.normalize()
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file, or one that is all comments, changes nothing
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// setting is a config value that a BBS_* environment variable and a
+// command-line flag can override.
+type setting struct {
+	env, flag, usage string
+	isBool           bool
+	set              func(value string) error
+}
+
+// settings lists everything that can be overridden from outside the
+// config file.
+func (c *Config) settings() []setting {
+	return []setting{
+		stringSetting("BBS_TELNET_ADDR", "telnet", "plain telnet listen `address`, \"off\" to disable", &c.Listen.Telnet),
+		stringSetting("BBS_TLS_ADDR", "tls", "telnet-over-TLS listen `address`", &c.Listen.TLS),
+		stringSetting("BBS_SSH_ADDR", "ssh", "SSH listen `address`", &c.Listen.SSH),
+		stringSetting("BBS_WEB_ADDR", "web", "HTTP/WebSocket listen `address`", &c.Listen.Web),
+		stringSetting("BBS_TLS_CERT", "tls-cert", "TLS certificate `file`", &c.TLS.CertFile),
+		stringSetting("BBS_TLS_KEY", "tls-key", "TLS private key `file`", &c.TLS.KeyFile),
+		stringSetting("BBS_SSH_HOST_KEY", "ssh-host-key", "SSH host key `file`", &c.SSH.HostKey),
+		listSetting("BBS_WEB_ORIGINS", "web-origins", "comma-separated `origins` besides its own allowed to open the WebSocket", &c.Web.AllowedOrigins),
+		stringSetting("BBS_DB_DRIVER", "db-driver", "database `driver`: sqlite, postgres or memory", &c.Database.Driver),
+		stringSetting("BBS_DB", "db", "SQLite database `path`", &c.Database.Path),
+		stringSetting("BBS_DB_DSN", "db-dsn", "PostgreSQL connection string (`dsn`)", &c.Database.DSN),
+		{
+			env: "BBS_ROOMS", flag: "rooms",
+			usage: "comma-separated `rooms` to seed, each name[:description]",
+			set: func(value string) error {
+				c.Seed.Rooms = parseRooms(value)
+				return nil
+			},
+		},
+		stringSetting("BBS_DEFAULT_ROOM", "default-room", "`room` new users start in", &c.Seed.DefaultRoom),
+		stringSetting("BBS_MOTD", "motd", "message of the day (`text`) for a fresh database", &c.Seed.MOTD),
+		intSetting("BBS_MIN_USERNAME_LENGTH", "min-username-length", "shortest username allowed (`n`)", &c.Limits.MinUsernameLength),
+		intSetting("BBS_MAX_USERNAME_LENGTH", "max-username-length", "longest username allowed (`n`)", &c.Limits.MaxUsernameLength),
+		intSetting("BBS_MIN_PASSWORD_LENGTH", "min-password-length", "shortest password allowed (`n`)", &c.Limits.MinPasswordLength),
+		intSetting("BBS_MAX_MESSAGE_LENGTH", "max-message-length", "longest chat message allowed (`n`)", &c.Limits.MaxMessageLength),
+		intSetting("BBS_HISTORY_SIZE", "history-size", "messages shown on a first visit to a room (`n`)", &c.Limits.HistorySize),
+		intSetting("BBS_CATCH_UP_SIZE", "catch-up-size", "most unread messages shown on rejoining a room (`n`)", &c.Limits.CatchUpSize),
+		intSetting("BBS_EDIT_WINDOW", "edit-window", "minutes a message can be edited, 0 for no limit (`n`)", &c.Limits.EditWindow),
+		intSetting("BBS_MAX_CONNECTIONS", "max-connections", "most connections at once, 0 for unlimited (`n`)", &c.Limits.MaxConnections),
+		intSetting("BBS_ROOM_IDLE_DAYS", "room-idle-days", "days before an idle user room is archived, 0 for never (`n`)", &c.Limits.RoomIdleDays),
+		intSetting("BBS_SEND_QUEUE", "send-queue", "messages kept waiting for a slow client (`n`)", &c.Limits.SendQueue),
+		stringSetting("BBS_SEND_OVERFLOW", "send-overflow", "`policy` when the send queue fills: drop_oldest or disconnect", &c.Limits.SendOverflow),
+		durationSetting("BBS_LOGIN_TIMEOUT", "login-timeout", "time allowed to log in, 0 for no limit (`duration`)", &c.Timeouts.Login),
+		durationSetting("BBS_AWAY_AFTER", "away-after", "idle time before shown as away, 0 for never (`duration`)", &c.Timeouts.Away),
+		durationSetting("BBS_IDLE_TIMEOUT", "idle-timeout", "idle time before being logged out, 0 for never (`duration`)", &c.Timeouts.Idle),
+		durationSetting("BBS_KEEPALIVE", "keepalive", "time between keepalive probes, 0 for none (`duration`)", &c.Timeouts.Keepalive),
+		boolSetting("BBS_REGISTRATION", "registration", "allow new accounts", &c.Features.Registration),
+		boolSetting("BBS_SSH_KEY_LOGIN", "ssh-key-login", "allow SSH public-key login", &c.Features.SSHKeyLogin),
+		listSetting("BBS_SYSOPS", "sysops", "comma-separated `usernames` made sysop at login", &c.Roles.Sysops),
+		stringSetting("BBS_CREATE_ROOMS", "create-rooms", "least trusted `role` allowed to create rooms", &c.Roles.CreateRooms),
+	}
+}
+
+func stringSetting(env, name, usage string, target *string) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(value string) error {
+		*target = value
+		return nil
+	}}
+}
+
+func intSetting(env, name, usage string, target *int) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*target = n
+		return nil
+	}}
+}
+
+func durationSetting(env, name, usage string, target *time.Duration) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 90s or 10m", value)
+		}
+		*target = d
+		return nil
+	}}
+}
+
+func boolSetting(env, name, usage string, target *bool) setting {
+	return setting{env: env, flag: name, usage: usage, isBool: true, set: func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true/false", value)
+		}
+		*target = b
+		return nil
+	}}
+}
+
+// listSetting takes a comma-separated list, e.g. BBS_SYSOPS=alice,bob.
+func listSetting(env, name, usage string, target *[]string) setting {
+	return setting{env: env, flag: name, usage: usage, set: func(value string) error {
+		*target = splitList(value)
+		return nil
+	}}
+}
+
+// parseRooms reads rooms given as "General:Chat about anything,Tech".
+// Descriptions can't contain commas.
+func parseRooms(value string) []RoomConfig {
+	var rooms []RoomConfig
+	for _, item := range splitList(value) {
+		name, description, _ := strings.Cut(item, ":")
+		rooms = append(rooms, RoomConfig{Name: strings.TrimSpace(name), Description: strings.TrimSpace(description)})
+	}
+	return rooms
+}
+
+// flagValue holds a flag's text until it is applied over the config file
+// and environment.
+type flagValue struct {
+	value  string
+	given  bool
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.value
+}
+
+func (v *flagValue) Set(value string) error {
+	v.value, v.given = value, true
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool { return v.isBool }
+
+// normalize turns the "off" spelling of a disabled listener into "".
+func (c *Config) normalize() {
+	for _, addr := range []*string{&c.Listen.Telnet, &c.Listen.TLS, &c.Listen.SSH, &c.Listen.Web} {
+		if strings.EqualFold(*addr, "off") {
+			*addr = ""
+		}
+	}
+}
+
+// Validate reports every problem with the configuration at once.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	listeners := []struct{ name, addr string }{
+		{"listen.telnet", c.Listen.Telnet},
+		{"listen.tls", c.Listen.TLS},
+		{"listen.ssh", c.Listen.SSH},
+		{"listen.web", c.Listen.Web},
+	}
+	enabled := 0
+	seenAddrs := make(map[string]string)
+	for _, listener := range listeners {
+		name, addr := listener.name, listener.addr
+		if addr == "" {
+			continue
+		}
+		enabled++
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			add("%s: invalid address %q (want host:port or :port)", name, addr)
+		}
+		// Port 0 picks a free port for each listener, so they can't clash
+		if other, ok := seenAddrs[addr]; ok && port != "0" {
+			add("%s and %s both listen on %s", name, other, addr)
+		}
+		seenAddrs[addr] = name
+	}
+	if enabled == 0 {
+		add("no listeners enabled")
+	}
+
+	if c.Listen.TLS != "" && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		add("tls: cert and key paths are required when listen.tls is set")
+	}
+	if c.Listen.SSH != "" && c.SSH.HostKey == "" {
+		add("ssh.host_key is required when listen.ssh is set")
+	}
+	for _, origin := range c.Web.AllowedOrigins {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "" || strings.Trim(u.Path, "/") != "") {
+			add("web.allowed_origins: %q is not an origin like https://bbs.example.com", origin)
+		}
+	}
+	switch c.Database.Driver {
+	case storage.DriverSQLite:
+		if c.Database.Path == "" {
+			add("database.path is required for the sqlite driver")
+		}
+	case storage.DriverPostgres:
+		if c.Database.DSN == "" {
+			add("database.dsn is required for the postgres driver")
+		}
+	case storage.DriverMemory:
+	default:
+		add("database.driver %q is not one of sqlite, postgres, memory", c.Database.Driver)
+	}
+
+	// Seed rooms follow the same naming rule as rooms users create, and
+	// are looked up ignoring case
+	roomNames := make(map[string]bool)
+	defaultSeeded := false
+	for i, room := range c.Seed.Rooms {
+		if !storage.ValidRoomName(room.Name) {
+			add("seed.rooms[%d]: room names are 2 to %d letters, digits, '-' or '_', not %q", i, storage.MaxRoomNameLength, room.Name)
+		}
+		if roomNames[strings.ToLower(room.Name)] {
+			add("seed.rooms: duplicate room %q", room.Name)
+		}
+		roomNames[strings.ToLower(room.Name)] = true
+		defaultSeeded = defaultSeeded || room.Name == c.Seed.DefaultRoom
+	}
+	if !storage.ValidRoomName(c.Seed.DefaultRoom) {
+		add("seed.default_room: room names are 2 to %d letters, digits, '-' or '_', not %q", storage.MaxRoomNameLength, c.Seed.DefaultRoom)
+	} else if !defaultSeeded {
+		add("seed.default_room %q is not one of seed.rooms", c.Seed.DefaultRoom)
+	}
+
+	boardNames := make(map[string]bool)
+	for i, board := range c.Seed.Boards {
+		if strings.TrimSpace(board.Name) == "" || strings.ContainsAny(board.Name, " \t") {
+			add("seed.boards[%d]: name is required and may not contain spaces", i)
+		}
+		if boardNames[strings.ToLower(board.Name)] {
+			add("seed.boards: duplicate board %q", board.Name)
+		}
+		boardNames[strings.ToLower(board.Name)] = true
+	}
+
+	l := c.Limits
+	if l.MinUsernameLength < 1 {
+		add("limits.min_username_length must be at least 1")
+	}
+	if l.MaxUsernameLength < l.MinUsernameLength {
+		add("limits.max_username_length must not be below min_username_length")
+	}
+	if l.MinPasswordLength < 1 {
+		add("limits.min_password_length must be at least 1")
+	}
+	if l.MaxMessageLength < 1 {
+		add("limits.max_message_length must be at least 1")
+	}
+	if l.HistorySize < 1 {
+		add("limits.history_size must be at least 1")
+	}
+	if l.CatchUpSize < 1 {
+		add("limits.catch_up_size must be at least 1")
+	}
+	if l.EditWindow < 0 {
+		add("limits.edit_window must not be negative")
+	}
+	if l.MaxConnections < 0 {
+		add("limits.max_connections must not be negative")
+	}
+	if l.RoomIdleDays < 0 {
+		add("limits.room_idle_days must not be negative")
+	}
+	if l.SendQueue < 1 {
+		add("limits.send_queue must be at least 1")
+	}
+	switch l.SendOverflow {
+	case OverflowDropOldest, OverflowDisconnect:
+	default:
+		add("limits.send_overflow must be one of %s", strings.Join(OverflowPolicies, ", "))
+	}
+
+	t := c.Timeouts
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{{"login", t.Login}, {"away", t.Away}, {"idle", t.Idle}, {"keepalive", t.Keepalive}} {
+		if timeout.value < 0 {
+			add("timeouts.%s must not be negative", timeout.name)
+		}
+	}
+	if t.Away > 0 && t.Idle > 0 && t.Away >= t.Idle {
+		add("timeouts.away must be shorter than timeouts.idle")
+	}
+
+	for _, name := range c.Roles.Sysops {
+		if name == "" || strings.ContainsAny(name, " \t") {
+			add("roles.sysops: invalid username %q", name)
+		}
+	}
+	if storage.RoleRank(c.Roles.CreateRooms) < 0 {
+		add("roles.create_rooms must be one of %s", strings.Join(storage.Roles, ", "))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// SeedData converts the seed rooms and boards for storage.Store.Seed.
+func (c *Config) SeedData() ([]storage.ChatRoom, []storage.Board) {
+	var rooms []storage.ChatRoom
+	for _, room := range c.Seed.Rooms {
+		rooms = append(rooms, storage.ChatRoom{Name: room.Name, Description: room.Description})
+	}
+	var boards []storage.Board
+	for _, board := range c.Seed.Boards {
+		boards = append(boards, storage.Board{Name: board.Name, Description: board.Description})
+	}
+	return rooms, boards
+}
+
+// String renders the resolved configuration as YAML for the startup log,
+// with any database password masked.
+func (c *Config) String() string {
+	redacted := *c
+	redacted.Database.DSN = redactDSN(c.Database.DSN)
+
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// redactDSN masks the password in a PostgreSQL URL or key=value
+// connection string.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return u.String()
+		}
+		return dsn
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// splitList splits a comma-separated list, dropping blank entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
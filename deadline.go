@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// readResult is one read from the stream under a deadlineReader.
+type readResult struct {
+	kind int // WebSocket message type; unused for plain streams
+	data []byte
+	err  error
+}
+
+// deadlineReader gives a stream that doesn't survive a read timeout, such
+// as a WebSocket or an SSH channel, read deadlines that can pass and be
+// moved again, like a TCP connection's. A goroutine does the actual
+// reading, so a Read can give up waiting without giving up the stream.
+type deadlineReader struct {
+	read    func() readResult // called on the reading goroutine
+	results chan readResult
+	start   sync.Once
+	err     error // once the stream has ended
+	closed  chan struct{}
+	stop    sync.Once
+
+	mutex    sync.Mutex
+	deadline time.Time
+	moved    chan struct{} // closed when the deadline changes
+}
+
+func newDeadlineReader(read func() readResult) *deadlineReader {
+	return &deadlineReader{
+		read:    read,
+		results: make(chan readResult),
+		closed:  make(chan struct{}),
+		moved:   make(chan struct{}),
+	}
+}
+
+func (r *deadlineReader) setDeadline(deadline time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deadline = deadline
+	close(r.moved)
+	r.moved = make(chan struct{})
+}
+
+// next returns the next read, or os.ErrDeadlineExceeded if the deadline
+// passes first. It is not safe for concurrent use.
+func (r *deadlineReader) next() readResult {
+	if r.err != nil {
+		return readResult{err: r.err}
+	}
+	r.start.Do(func() { go r.pump() })
+
+	for {
+		r.mutex.Lock()
+		deadline, moved := r.deadline, r.moved
+		r.mutex.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return readResult{err: os.ErrDeadlineExceeded}
+			}
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+
+		select {
+		case result := <-r.results:
+			if timer != nil {
+				timer.Stop()
+			}
+			r.err = result.err
+			return result
+		case <-expired:
+			return readResult{err: os.ErrDeadlineExceeded}
+		case <-moved:
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
+}
+
+// close lets the reading goroutine exit once the stream underneath has
+// been closed, even if nobody reads what it got.
+func (r *deadlineReader) close() {
+	r.stop.Do(func() { close(r.closed) })
+}
+
+func (r *deadlineReader) pump() {
+	for {
+		result := r.read()
+		select {
+		case r.results <- result:
+		case <-r.closed:
+			return
+		}
+		if result.err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Messages shown per page by "dms <user>"
+const dmPageSize = 20
+
+// sendDirectMessage stores a private message and delivers it at once to
+// every session the recipient has open.
+func (c *Client) sendDirectMessage(username, content string) {
+	recipient, err := c.db.GetUserByName(username)
+	if err == sql.ErrNoRows {
+		c.write(fmt.Sprintf("No such user '%s'.\n", username))
+		return
+	} else if err != nil {
+		c.write("Failed to send message.\n")
+		return
+	}
+	if recipient.ID == c.user.ID {
+		c.write("You can't send a message to yourself.\n")
+		return
+	}
+
+	if max := c.server.config.Limits.MaxMessageLength; len(content) > max {
+		c.write(fmt.Sprintf("Message too long (%d characters, limit is %d).\n", len(content), max))
+		return
+	}
+
+	if err := c.db.AddDirectMessage(c.user.ID, recipient.ID, content); err != nil {
+		c.write("Failed to send message.\n")
+		return
+	}
+
+	timestamp := time.Now().Format("15:04")
+	delivered := c.server.SendToUser(recipient.ID,
+		fmt.Sprintf("\033[90m[%s]\033[0m \033[35m%s -> you:\033[0m %s\n", timestamp, c.user.Username, content))
+	c.writeWrapped(fmt.Sprintf("\033[90m[%s]\033[0m \033[35myou -> %s:\033[0m %s\n", timestamp, recipient.Username, content))
+	if delivered == 0 {
+		c.write(fmt.Sprintf("\033[90m%s is offline and will find it with '/dms %s'.\033[0m\n", recipient.Username, c.user.Username))
+	}
+}
+
+// showDirectMessages lists conversations with no arguments, or shows one
+// page of the conversation with a user; page 1 is the newest.
+func (c *Client) showDirectMessages(args []string) {
+	if len(args) == 0 {
+		c.listCorrespondents()
+		return
+	}
+
+	other, err := c.db.GetUserByName(args[0])
+	if err != nil {
+		c.write(fmt.Sprintf("No such user '%s'.\n", args[0]))
+		return
+	}
+
+	page := 1
+	if len(args) > 1 {
+		page, err = strconv.Atoi(args[1])
+		if err != nil || page < 1 {
+			c.write("Usage: /dms <user> [page]\n")
+			return
+		}
+	}
+
+	total, err := c.db.CountDirectMessages(c.user.ID, other.ID)
+	if err != nil {
+		c.write("Error loading messages.\n")
+		return
+	}
+	if total == 0 {
+		c.write(fmt.Sprintf("No messages with %s yet.\n", other.Username))
+		return
+	}
+	pages := (total + dmPageSize - 1) / dmPageSize
+	if page > pages {
+		c.write(fmt.Sprintf("Only %d page(s) of messages with %s.\n", pages, other.Username))
+		return
+	}
+
+	messages, err := c.db.GetDirectMessages(c.user.ID, other.ID, dmPageSize, (page-1)*dmPageSize)
+	if err != nil {
+		c.write("Error loading messages.\n")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[35mMessages with %s (page %d of %d):\033[0m\n", other.Username, page, pages))
+	c.write(c.separator("-", 40) + "\n")
+	for _, msg := range messages {
+		from := msg.FromName
+		if msg.FromID == c.user.ID {
+			from = "you"
+		}
+		timestamp := msg.Timestamp.Format("2006-01-02 15:04")
+		c.writeWrapped(fmt.Sprintf("\033[90m[%s]\033[0m \033[33m%s:\033[0m %s\n", timestamp, from, msg.Content))
+	}
+	c.write(c.separator("-", 40) + "\n")
+	if page < pages {
+		c.write(fmt.Sprintf("Older messages: dms %s %d\n", other.Username, page+1))
+	}
+	c.write("\n")
+}
+
+func (c *Client) listCorrespondents() {
+	correspondents, err := c.db.GetCorrespondents(c.user.ID)
+	if err != nil {
+		c.write("Error loading conversations.\n")
+		return
+	}
+	if len(correspondents) == 0 {
+		c.write("No private messages yet. Send one with '/tell <user> <message>'.\n")
+		return
+	}
+
+	c.write("\033[36mPrivate conversations:\033[0m\n")
+	c.write(c.separator("-", 50) + "\n")
+	for _, correspondent := range correspondents {
+		c.write(fmt.Sprintf("\033[33m%-20s\033[0m %4d message(s), last %s\n",
+			correspondent.Username, correspondent.Messages,
+			correspondent.LastAt.Format("2006-01-02 15:04")))
+	}
+	c.write(c.separator("-", 50) + "\n")
+	c.write("Read one with '/dms <user>'.\n\n")
+}
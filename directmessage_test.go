@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTellAcrossRooms(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	bob.send("join Tech")
+	bob.expect("[Tech]> ")
+
+	alice.send("/tell bob meet me in Gaming")
+	alice.expect("you -> bob: meet me in Gaming")
+	bob.expect("alice -> you: meet me in Gaming")
+
+	bob.send("/w alice on my way")
+	alice.expect("bob -> you: on my way")
+}
+
+func TestTellOffline(t *testing.T) {
+	s := startTestServer(t, nil)
+	bob := s.register("bob", "secret")
+	bob.send("quit")
+	bob.expect("Goodbye!")
+
+	alice := s.register("alice", "secret")
+	alice.send("/tell bob are you there?")
+	alice.expect("bob is offline")
+
+	bob = s.login("bob", "secret")
+	bob.send("/dms")
+	out := bob.expectPrompt()
+	if !strings.Contains(out, "alice") || !strings.Contains(out, "1 message(s)") {
+		t.Errorf("dms listing: %q", out)
+	}
+
+	bob.send("/dms alice")
+	bob.expect("Messages with alice (page 1 of 1):")
+	bob.expect("alice: are you there?")
+}
+
+func TestTellErrors(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+
+	alice.send("/tell nobody hello")
+	alice.expect("No such user 'nobody'.")
+	alice.send("/tell alice hello")
+	alice.expect("You can't send a message to yourself.")
+	alice.send("/tell bob")
+	alice.expect("Usage: /tell <user> <message>")
+	alice.send("/dms")
+	alice.expect("No private messages yet.")
+}
+
+func TestDirectMessagePages(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	s.register("bob", "secret")
+
+	for i := 1; i <= dmPageSize+5; i++ {
+		alice.send(fmt.Sprintf("/tell bob message %d", i))
+		alice.expect(fmt.Sprintf("you -> bob: message %d\n", i))
+	}
+
+	alice.send("/dms bob")
+	out := alice.expect("Older messages: dms bob 2")
+	if !strings.Contains(out, "page 1 of 2") || !strings.Contains(out, "you: message 6\n") || strings.Contains(out, "you: message 5\n") {
+		t.Errorf("first page: %q", out)
+	}
+	alice.expectPrompt()
+
+	alice.send("/dms bob 2")
+	out = alice.expectPrompt()
+	if !strings.Contains(out, "you: message 1\n") || !strings.Contains(out, "you: message 5\n") || strings.Contains(out, "message 6\n") {
+		t.Errorf("second page: %q", out)
+	}
+
+	alice.send("/dms bob 3")
+	alice.expect("Only 2 page(s) of messages with bob.")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	s := startTestServer(t, nil)
+
+	alice := s.register("alice", "secret")
+	alice.send("quit")
+	alice.expect("Goodbye!")
+
+	bob := s.login("alice", "secret")
+	bob.send("users")
+	if out := bob.expectPrompt(); !strings.Contains(out, "alice (you)") {
+		t.Errorf("users after login: %q", out)
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	s := startTestServer(t, nil)
+	s.register("alice", "secret")
+
+	c := s.dial()
+	c.expect("(L)ogin or (R)egister?")
+	c.send("l")
+	c.expect("Username:")
+	c.send("alice")
+	c.expect("Password:")
+	c.send("wrong")
+	c.expect("Invalid username or password.")
+	c.expect("(L)ogin or (R)egister?")
+}
+
+func TestRegistrationClosed(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Features.Registration = false
+	})
+
+	c := s.dial()
+	c.expect("(L)ogin or (R)egister?")
+	c.send("r")
+	c.expect("Registration of new accounts is closed.")
+}
+
+func TestJoinRoom(t *testing.T) {
+	s := startTestServer(t, nil)
+	c := s.register("alice", "secret")
+
+	c.send("join Tech")
+	c.expect("Joined room: Tech")
+	c.expect("No recent messages in this room.")
+	c.expect("[Tech]> ")
+
+	c.send("join Tech")
+	c.expect("You are already in this room.")
+
+	c.send("join Nowhere")
+	c.expect("Room 'Nowhere' not found.")
+}
+
+func TestMessageBroadcast(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	alice.expect("*** bob joined the room ***")
+
+	bob.send("msg hello everyone")
+	bob.expectPrompt()
+	alice.expect("bob: hello everyone")
+
+	// Plain text without a command is a message too
+	alice.send("hi bob")
+	bob.expect("alice: hi bob")
+}
+
+func TestBroadcastStaysInRoom(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	bob.send("join Tech")
+	bob.expect("[Tech]> ")
+
+	alice.send("msg only for General")
+	alice.expectPrompt()
+	bob.expectNothing("only for General")
+}
+
+func TestHistoryOnJoin(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	alice.send("msg remember this")
+	alice.expectPrompt()
+
+	bob := s.dial()
+	bob.expect("(L)ogin or (R)egister?")
+	bob.send("r")
+	bob.expect("Choose a username:")
+	bob.send("bob")
+	bob.expect("Choose a password:")
+	bob.send("secret")
+	bob.expect("Recent messages in General:")
+	bob.expect("alice: remember this")
+}
+
+func TestCatchUpSinceLastVisit(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Limits.CatchUpSize = 2
+	})
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	bob.send("join Tech")
+	bob.expect("[Tech]> ")
+
+	for _, msg := range []string{"one", "two", "three"} {
+		alice.send("msg " + msg)
+		alice.expectPrompt()
+	}
+
+	bob.send("rooms")
+	if out := bob.expectPrompt(); !strings.Contains(out, "General - General discussion for all users (3 new)") {
+		t.Errorf("rooms: %q", out)
+	}
+
+	bob.send("join General")
+	out := bob.expect("[General]> ")
+	if !strings.Contains(out, "New in General since your last visit:") || !strings.Contains(out, "(1 more, type 'history' to see all)") ||
+		!strings.Contains(out, "alice: three") || strings.Contains(out, "alice: one") {
+		t.Errorf("catch-up: %q", out)
+	}
+
+	bob.send("join Tech")
+	bob.expect("[Tech]> ")
+	bob.send("join General")
+	bob.expect("No new messages since your last visit.")
+}
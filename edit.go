@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+// findOwnMessage looks up one of the user's messages that may still be
+// changed, by ID or "last" for their newest in the current room. It tells
+// the user why if there isn't one.
+func (c *Client) findOwnMessage(ref string) (*storage.Message, bool) {
+	var msg *storage.Message
+	var err error
+	if strings.EqualFold(ref, "last") {
+		if c.currentRoom == nil {
+			c.write("You are not in a chat room.\n")
+			return nil, false
+		}
+		msg, err = c.db.GetLastMessage(c.currentRoom.ID, c.user.ID)
+		if err == sql.ErrNoRows {
+			c.write("You haven't said anything in this room.\n")
+			return nil, false
+		}
+	} else {
+		id, convErr := strconv.Atoi(strings.TrimPrefix(ref, "#"))
+		if convErr != nil {
+			c.write(fmt.Sprintf("'%s' is not a message ID.\n", ref))
+			return nil, false
+		}
+		msg, err = c.db.GetMessage(id)
+		if err == sql.ErrNoRows || (err == nil && msg.UserID != c.user.ID) {
+			c.write(fmt.Sprintf("You have no message %d.\n", id))
+			return nil, false
+		}
+	}
+	if err != nil {
+		c.write("Error loading message.\n")
+		return nil, false
+	}
+
+	if window := c.server.config.Limits.EditWindow; window > 0 && time.Since(msg.Timestamp) > time.Duration(window)*time.Minute {
+		c.write(fmt.Sprintf("Message %d is too old to change (limit is %d minutes).\n", msg.ID, window))
+		return nil, false
+	}
+	return msg, true
+}
+
+// editMessage replaces the text of one of the user's messages and shows
+// the new version to everyone in its room.
+func (c *Client) editMessage(args []string) {
+	if len(args) < 2 {
+		c.write("Usage: /edit <message id|last> <new text>\n")
+		return
+	}
+	msg, ok := c.findOwnMessage(args[0])
+	if !ok {
+		return
+	}
+
+	content := strings.Join(args[1:], " ")
+	if max := c.server.config.Limits.MaxMessageLength; len(content) > max {
+		c.write(fmt.Sprintf("Message too long (%d characters, limit is %d).\n", len(content), max))
+		return
+	}
+	if content == msg.Content {
+		c.write("That's what it already says.\n")
+		return
+	}
+	// An edit is shown to the room like a new message
+	if mute, err := c.db.GetActiveMute(c.user.ID, msg.RoomID); err == nil {
+		c.write(fmt.Sprintf("\033[31mYou are muted %s %s.\033[0m\n", sanctionScope(mute), sanctionExpiry(mute)))
+		return
+	}
+
+	if err := c.db.EditMessage(msg.ID, content); err != nil {
+		c.reviseFailed(msg, err, "edit")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[32mMessage %d edited.\033[0m\n", msg.ID))
+	notice := fmt.Sprintf("\033[90m[%s]\033[0m \033[33m%s:\033[0m %s \033[90m(edited)\033[0m\n",
+		msg.Timestamp.Format("15:04"), c.user.Username, content)
+	c.server.BroadcastToRoom(msg.RoomID, notice, c)
+}
+
+// deleteMessage removes one of the user's messages and tells everyone in
+// its room.
+func (c *Client) deleteMessage(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /delete <message id|last>\n")
+		return
+	}
+	msg, ok := c.findOwnMessage(args[0])
+	if !ok {
+		return
+	}
+
+	if err := c.db.DeleteMessage(msg.ID); err != nil {
+		c.reviseFailed(msg, err, "delete")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[32mMessage %d deleted.\033[0m\n", msg.ID))
+	notice := fmt.Sprintf("\033[90m*** %s deleted their message from %s ***\033[0m\n",
+		c.user.Username, msg.Timestamp.Format("15:04"))
+	c.server.BroadcastToRoom(msg.RoomID, notice, c)
+}
+
+// reviseFailed tells the user why a message couldn't be edited or deleted.
+func (c *Client) reviseFailed(msg *storage.Message, err error, action string) {
+	if err != storage.ErrRoomArchived {
+		c.write(fmt.Sprintf("Failed to %s message.\n", action))
+		return
+	}
+	name := "The room"
+	rooms, _ := c.db.GetChatRooms()
+	for _, room := range rooms {
+		if room.ID == msg.RoomID {
+			name = room.Name
+		}
+	}
+	c.write(fmt.Sprintf("%s is archived and read-only.\n", name))
+}
+
+// editedMarker is appended to messages that have been edited.
+func editedMarker(msg storage.Message) string {
+	if msg.EditedAt.IsZero() {
+		return ""
+	}
+	return " \033[90m(edited)\033[0m"
+}
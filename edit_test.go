@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditAndDeleteMessage(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	alice.expect("*** bob joined the room ***")
+
+	alice.send("msg teh quick fox")
+	alice.expectPrompt()
+	bob.expect("alice: teh quick fox")
+
+	alice.send("/edit last the quick fox")
+	alice.expect("Message 1 edited.")
+	alice.expectPrompt()
+	bob.expect("alice: the quick fox (edited)")
+
+	bob.send("history")
+	if out := bob.expectPrompt(); !strings.Contains(out, "alice: the quick fox (edited)") || strings.Contains(out, "teh") {
+		t.Errorf("history after edit: %q", out)
+	}
+
+	bob.send("/edit 1 mine now")
+	bob.expect("You have no message 1.")
+	bob.send("/delete 1")
+	bob.expect("You have no message 1.")
+
+	alice.send("/delete 1")
+	alice.expect("Message 1 deleted.")
+	alice.expectPrompt()
+	bob.expect("*** alice deleted their message from ")
+
+	bob.send("history")
+	bob.expect("No messages found.")
+	alice.send("/delete last")
+	alice.expect("You haven't said anything in this room.")
+	alice.send("/edit 1 again")
+	alice.expect("You have no message 1.")
+
+	revisions, err := s.db.GetRevisions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Content != "teh quick fox" || revisions[1].Content != "the quick fox" {
+		t.Errorf("revisions: %+v", revisions)
+	}
+}
+
+func TestRevisionsNeedRoomAccess(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	s.privateRoom("Den", "bob")
+
+	alice.send("/role carol moderator")
+	alice.expect("carol is now a moderator.")
+	bob.send("join Den")
+	bob.expect("Joined room: Den")
+	bob.send("meet at the old mill")
+	bob.expectPrompt()
+	bob.send("/edit last meet at noon")
+	bob.expect("Message 1 edited.")
+
+	carol.send("/revisions 1")
+	carol.expect("Message 1 has never been edited or deleted.")
+	alice.send("/revisions 1")
+	alice.expect("meet at the old mill")
+}
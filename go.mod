@@ -1,8 +1,13 @@
 module bbs
 
-go 1.21
+go 1.23.0
 
 require (
+	github.com/gorilla/websocket v1.5.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.17
-	golang.org/x/crypto v0.14.0
+	golang.org/x/crypto v0.35.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.30.0 // indirect
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+// How long expect waits for output before failing the test
+const testTimeout = 5 * time.Second
+
+// testServer is a BBSServer on a random local telnet port backed by a
+// private in-memory store.
+type testServer struct {
+	*BBSServer
+	t    *testing.T
+	addr string
+}
+
+// startTestServer starts a server seeded with the default rooms and MOTD.
+// configure, if not nil, may adjust the config first. The server stops
+// when the test ends.
+func startTestServer(t *testing.T, configure func(*Config)) *testServer {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.Listen.Telnet = "127.0.0.1:0"
+	config.Database.Driver = storage.DriverMemory
+	if configure != nil {
+		configure(config)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := storage.NewDatabase(config.Database.Driver, config.Database.Source())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rooms, boards := config.SeedData()
+	if err := db.Seed(rooms, boards, config.Seed.MOTD); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewBBSServer(db, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx) }()
+
+	select {
+	case <-server.Ready():
+	case err := <-done:
+		t.Fatalf("server failed to start: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		db.Close()
+	})
+
+	return &testServer{BBSServer: server, t: t, addr: server.Addr("telnet").String()}
+}
+
+// dial connects a new telnet client.
+func (s *testServer) dial() *testClient {
+	s.t.Helper()
+
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { conn.Close() })
+
+	return &testClient{t: s.t, conn: conn, telnet: NewTelnetConn(conn)}
+}
+
+// register connects and creates a new account, leaving the client at the
+// command prompt in the default room.
+func (s *testServer) register(username, password string) *testClient {
+	s.t.Helper()
+
+	c := s.dial()
+	c.expect("(L)ogin or (R)egister?")
+	c.send("r")
+	c.expect("Choose a username:")
+	c.send(username)
+	c.expect("Choose a password:")
+	c.send(password)
+	c.expect("Welcome, " + username)
+	c.expectPrompt()
+	return c
+}
+
+// login connects with an existing account, leaving the client at the
+// command prompt in the default room.
+func (s *testServer) login(username, password string) *testClient {
+	s.t.Helper()
+
+	c := s.dial()
+	c.expect("(L)ogin or (R)egister?")
+	c.send("l")
+	c.expect("Username:")
+	c.send(username)
+	c.expect("Password:")
+	c.send(password)
+	c.expect("Welcome back, " + username)
+	c.expectPrompt()
+	return c
+}
+
+// testClient is the user's side of a telnet session. Telnet negotiation
+// is answered by a client-side TelnetConn and ANSI escapes are dropped, so
+// tests match on plain text.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	telnet *TelnetConn
+
+	pending []byte // received text not yet consumed by expect
+	escape  bool   // inside an escape sequence split across reads
+	csi     bool
+}
+
+// send types a line.
+func (c *testClient) send(line string) {
+	c.t.Helper()
+	if _, err := c.telnet.Write([]byte(line + "\n")); err != nil {
+		c.t.Fatalf("sending %q: %v", line, err)
+	}
+}
+
+// expect waits for want to appear in the output and returns everything up
+// to and including it. Output before want is consumed.
+func (c *testClient) expect(want string) string {
+	c.t.Helper()
+
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if i := strings.Index(string(c.pending), want); i >= 0 {
+			text := string(c.pending[:i+len(want)])
+			c.pending = c.pending[i+len(want):]
+			return text
+		}
+
+		c.conn.SetReadDeadline(deadline)
+		n, err := c.telnet.Read(buf)
+		c.appendPlain(buf[:n])
+		if err != nil {
+			c.t.Fatalf("waiting for %q: %v\nreceived: %q", want, err, c.pending)
+		}
+	}
+}
+
+// expectPrompt waits for the command prompt.
+func (c *testClient) expectPrompt() string {
+	c.t.Helper()
+	return c.expect("]> ")
+}
+
+// expectClosed waits for the server to close the connection.
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+
+	buf := make([]byte, 4096)
+	c.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	for {
+		n, err := c.telnet.Read(buf)
+		c.appendPlain(buf[:n])
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			c.t.Fatalf("waiting for the connection to close: %v\nreceived: %q", err, c.pending)
+		}
+	}
+}
+
+// expectNothing checks that no output containing unwanted arrives within
+// a short time.
+func (c *testClient) expectNothing(unwanted string) {
+	c.t.Helper()
+
+	buf := make([]byte, 4096)
+	c.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		n, err := c.telnet.Read(buf)
+		c.appendPlain(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	if strings.Contains(string(c.pending), unwanted) {
+		c.t.Fatalf("unexpected %q in output: %q", unwanted, c.pending)
+	}
+}
+
+// appendPlain adds received data to pending with ANSI escapes removed.
+func (c *testClient) appendPlain(data []byte) {
+	for _, b := range data {
+		switch {
+		case c.escape:
+			if b == '[' && !c.csi {
+				c.csi = true
+			} else if !c.csi || (b >= 0x40 && b <= 0x7e) {
+				c.escape, c.csi = false, false
+			}
+		case b == '\033':
+			c.escape = true
+		default:
+			c.pending = append(c.pending, b)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+const historyUsage = "Usage: history [count] [before <id>] [since <date> [hh:mm]] [until <date> [hh:mm]] [by <user>]\n"
+
+// showHistory pages back through the current room's messages, a screenful
+// at a time, newest first.
+func (c *Client) showHistory(args []string) {
+	if c.currentRoom == nil {
+		c.write("You are not in a chat room.\n")
+		return
+	}
+	if c.leaveLostRoom() {
+		return
+	}
+
+	q, count, ok := c.parseHistoryArgs(args)
+	if !ok {
+		return
+	}
+	q.RoomID = c.currentRoom.ID
+
+	c.write(fmt.Sprintf("\033[35mHistory of %s:\033[0m\n", c.currentRoom.Name))
+	// Leave room for the header on the first page and the --More-- line,
+	// but show at least a message a page however short the window
+	_, height := c.windowSize()
+	rows := max(height-3, 1)
+	shown := 0
+	for {
+		limit := rows
+		if count > 0 && count-shown < limit {
+			limit = count - shown
+		}
+		messages, err := c.db.GetHistory(q, limit)
+		if err != nil {
+			c.write("Error loading history.\n")
+			return
+		}
+		if len(messages) == 0 {
+			if shown == 0 {
+				c.write("No messages found.\n")
+			} else {
+				c.write("No older messages.\n")
+			}
+			return
+		}
+		more := len(messages) == limit
+
+		page := c.fitRows(messages, rows)
+		for _, msg := range page {
+			c.write(c.formatHistoryMessage(msg))
+		}
+		shown += len(page)
+		q.BeforeID = page[0].ID
+		more = more || len(page) < len(messages)
+		if !more || (count > 0 && shown >= count) {
+			return
+		}
+
+		rows = max(height-2, 1)
+		c.write("\033[7m--More--\033[0m (Enter for older messages, q to stop) ")
+		if !c.scanner.Scan() {
+			return
+		}
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(c.scanner.Text())), "q") {
+			c.write(fmt.Sprintf("Older messages: history before %d\n", q.BeforeID))
+			return
+		}
+	}
+}
+
+// fitRows drops the oldest messages until the rest fit in rows screen
+// lines once wrapped. At least one message is always kept.
+func (c *Client) fitRows(messages []storage.Message, rows int) []storage.Message {
+	used := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		used += strings.Count(c.formatHistoryMessage(messages[i]), "\n")
+		if used > rows && i < len(messages)-1 {
+			return messages[i+1:]
+		}
+	}
+	return messages
+}
+
+func (c *Client) formatHistoryMessage(msg storage.Message) string {
+	width, _ := c.windowSize()
+	line := fmt.Sprintf("\033[90m#%d [%s]\033[0m \033[33m%s:\033[0m %s%s\n",
+		msg.ID, msg.Timestamp.Format("2006-01-02 15:04"), msg.Username, msg.Content, editedMarker(msg))
+	return wrapText(line, width, chatIndent)
+}
+
+// parseHistoryArgs turns the history command's arguments into a query and
+// a message count, 0 for no limit. It reports problems to the user.
+func (c *Client) parseHistoryArgs(args []string) (storage.HistoryQuery, int, bool) {
+	var q storage.HistoryQuery
+	count := 0
+
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 && count == 0 {
+			count = n
+			continue
+		}
+		if i+1 >= len(args) {
+			c.write(historyUsage)
+			return q, 0, false
+		}
+
+		switch arg {
+		case "before":
+			id, err := strconv.Atoi(args[i+1])
+			if err != nil || id < 1 {
+				c.write(historyUsage)
+				return q, 0, false
+			}
+			q.BeforeID = id
+			i++
+		case "by":
+			user, err := c.db.GetUserByName(args[i+1])
+			if err != nil {
+				c.write(fmt.Sprintf("No such user '%s'.\n", args[i+1]))
+				return q, 0, false
+			}
+			q.UserID = user.ID
+			i++
+		case "since", "until":
+			t, hasTime, err := parseHistoryTime(args[i+1:])
+			if err != nil {
+				c.write(fmt.Sprintf("Can't read '%s' as a date: use YYYY-MM-DD, optionally followed by HH:MM.\n", args[i+1]))
+				return q, 0, false
+			}
+			i++
+			if hasTime {
+				i++
+			}
+			if arg == "since" {
+				q.Since = t
+			} else {
+				// "until 2024-05-01" includes the whole day
+				if !hasTime {
+					t = t.AddDate(0, 0, 1)
+				}
+				q.Until = t
+			}
+		default:
+			c.write(historyUsage)
+			return q, 0, false
+		}
+	}
+
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Since.Before(q.Until) {
+		c.write("The 'since' time must be before the 'until' time.\n")
+		return q, 0, false
+	}
+	return q, count, true
+}
+
+// parseHistoryTime reads a date and an optional following time of day from
+// args. Times are UTC, like the timestamps history shows.
+func parseHistoryTime(args []string) (time.Time, bool, error) {
+	if len(args) > 1 {
+		if t, err := time.Parse("2006-01-02 15:04", args[0]+" "+args[1]); err == nil {
+			return t, true, nil
+		}
+	}
+	t, err := time.Parse("2006-01-02", args[0])
+	return t, false, err
+}
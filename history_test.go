@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryPager(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+
+	// 30 messages don't fit on the default 24 line terminal
+	for i := 1; i <= 30; i++ {
+		alice.send(fmt.Sprintf("msg line %d", i))
+		alice.expectPrompt()
+	}
+
+	alice.send("history")
+	out := alice.expect("--More--")
+	if !strings.Contains(out, "alice: line 30\n") || !strings.Contains(out, "alice: line 10\n") || strings.Contains(out, "alice: line 9\n") {
+		t.Fatalf("first page: %q", out)
+	}
+	if lines := strings.Count(out, "\n"); lines > defaultTermHeight-1 {
+		t.Errorf("first page is %d lines", lines)
+	}
+
+	alice.send("")
+	out = alice.expectPrompt()
+	if !strings.Contains(out, "alice: line 9\n") || !strings.Contains(out, "alice: line 1\n") || strings.Contains(out, "--More--") {
+		t.Fatalf("second page: %q", out)
+	}
+
+	alice.send("history 5")
+	out = alice.expectPrompt()
+	if strings.Contains(out, "--More--") || !strings.Contains(out, "line 26\n") || strings.Contains(out, "line 25\n") {
+		t.Errorf("history 5: %q", out)
+	}
+
+	alice.send("history")
+	alice.expect("--More--")
+	alice.send("q")
+	alice.expect("Older messages: history before ")
+	alice.expectPrompt()
+}
+
+func TestHistoryFilters(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	alice.send("msg from alice")
+	alice.expectPrompt()
+	bob.send("msg from bob")
+	bob.expectPrompt()
+
+	bob.send("history by alice")
+	out := bob.expectPrompt()
+	if !strings.Contains(out, "alice: from alice") || strings.Contains(out, "bob: from bob") {
+		t.Errorf("by alice: %q", out)
+	}
+
+	// The first message in the room has ID 1
+	bob.send("history before 2")
+	out = bob.expectPrompt()
+	if !strings.Contains(out, "#1 [") || strings.Contains(out, "from bob") {
+		t.Errorf("before 2: %q", out)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	bob.send("history since " + today + " until " + today)
+	if out := bob.expectPrompt(); !strings.Contains(out, "from alice") || !strings.Contains(out, "from bob") {
+		t.Errorf("today: %q", out)
+	}
+	bob.send("history until 2000-01-01 12:00")
+	bob.expect("No messages found.")
+
+	bob.send("history by nobody")
+	bob.expect("No such user 'nobody'.")
+	bob.send("history since yesterday")
+	bob.expect("Can't read 'yesterday' as a date")
+	bob.send("history sideways")
+	bob.expect("Usage: history [count]")
+}
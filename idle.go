@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// idleReader is what a Client's scanner reads from. Before each read it
+// sets the connection's read deadline to the next thing due if the user
+// stays quiet (see timeouts in the config); when the deadline passes it
+// does that thing and reads on, unless it was the end of the session.
+type idleReader struct {
+	c *Client
+}
+
+func (r idleReader) Read(p []byte) (int, error) {
+	c := r.c
+	// Idleness counts from when the BBS is ready for more, not from the
+	// last keypress, which may have started something slow
+	c.lastInput = time.Now()
+	for {
+		c.term.SetReadDeadline(c.nextIdleEvent())
+		n, err := c.term.Read(p)
+		if n > 0 {
+			c.idleWarned = false
+			c.away.Store(false)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				err = nil
+			}
+			return n, err
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			return n, err
+		}
+		if !c.idleTimeout(time.Now()) {
+			return 0, err
+		}
+	}
+}
+
+// idleWarning is how long before being logged out for idleness the user
+// is warned: a minute, or half the timeout if that is shorter.
+func idleWarning(idle time.Duration) time.Duration {
+	if idle < 2*time.Minute {
+		return idle / 2
+	}
+	return time.Minute
+}
+
+// nextIdleEvent returns when the next thing is due if the user stays
+// quiet, or the zero time if nothing ever is.
+func (c *Client) nextIdleEvent() time.Time {
+	t := c.server.config.Timeouts
+	var next time.Time
+	due := func(at time.Time) {
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+
+	if t.Keepalive > 0 && c.telnet != nil {
+		due(c.lastProbe.Add(t.Keepalive))
+	}
+	if !c.authenticated {
+		if t.Login > 0 {
+			due(c.connectedAt.Add(t.Login))
+		}
+		return next
+	}
+	if t.Away > 0 && !c.away.Load() {
+		due(c.lastInput.Add(t.Away))
+	}
+	if t.Idle > 0 {
+		if !c.idleWarned {
+			due(c.lastInput.Add(t.Idle - idleWarning(t.Idle)))
+		}
+		due(c.lastInput.Add(t.Idle))
+	}
+	return next
+}
+
+// idleTimeout does whatever has come due while the user was quiet and
+// reports whether the session goes on.
+func (c *Client) idleTimeout(now time.Time) bool {
+	t := c.server.config.Timeouts
+
+	// A telnet NOP to a peer that has gone away eventually fails, where
+	// waiting for it to type something never would
+	if t.Keepalive > 0 && c.telnet != nil && !now.Before(c.lastProbe.Add(t.Keepalive)) {
+		c.lastProbe = now
+		if err := c.telnet.Ping(); err != nil {
+			return false
+		}
+	}
+
+	if !c.authenticated {
+		if t.Login > 0 && !now.Before(c.connectedAt.Add(t.Login)) {
+			log.Printf("Login timed out for %s", c.conn.RemoteAddr())
+			c.write("\n\033[31mLogin timed out. Goodbye!\033[0m\n")
+			return false
+		}
+		return true
+	}
+
+	idle := now.Sub(c.lastInput)
+	if t.Idle > 0 && idle >= t.Idle {
+		log.Printf("User %s logged out after being idle for %s", c.user.Username, t.Idle)
+		c.write(fmt.Sprintf("\n\033[31mYou have been idle for %s and are being logged out. Goodbye!\033[0m\n", describeWait(t.Idle)))
+		return false
+	}
+	if t.Idle > 0 && !c.idleWarned && idle >= t.Idle-idleWarning(t.Idle) {
+		c.idleWarned = true
+		c.write(fmt.Sprintf("\n\033[33m*** You will be logged out for being idle in %s unless you type something ***\033[0m\n",
+			describeWait(idleWarning(t.Idle))))
+	}
+	if t.Away > 0 && idle >= t.Away {
+		c.away.Store(true)
+	}
+	return true
+}
+
+// describeWait renders a timeout in whole minutes, or seconds below one.
+func describeWait(d time.Duration) string {
+	if d < time.Minute {
+		if seconds := int(d.Round(time.Second) / time.Second); seconds != 1 {
+			return fmt.Sprintf("%d seconds", seconds)
+		}
+		return "1 second"
+	}
+	if minutes := int(d.Round(time.Minute) / time.Minute); minutes != 1 {
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return "1 minute"
+}
+
+// AwayUsers returns the users online whose every session has been idle
+// for timeouts.away.
+func (s *BBSServer) AwayUsers() map[string]bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	away := make(map[string]bool)
+	for client := range s.clients {
+		name := client.user.Username
+		if wasAway, seen := away[name]; !seen || wasAway {
+			away[name] = client.away.Load()
+		}
+	}
+	for name, isAway := range away {
+		if !isAway {
+			delete(away, name)
+		}
+	}
+	return away
+}
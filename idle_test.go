@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginTimeout(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Timeouts.Login = 300 * time.Millisecond
+	})
+
+	c := s.dial()
+	c.expect("(L)ogin or (R)egister?")
+	c.expect("Login timed out.")
+	c.expectClosed()
+}
+
+func TestSilentConnectionsTimeOut(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Listen.SSH = "127.0.0.1:0"
+		config.SSH.HostKey = filepath.Join(t.TempDir(), "ssh_host_ed25519_key")
+		config.Listen.Web = "127.0.0.1:0"
+		config.Listen.TLS = "127.0.0.1:0"
+		config.TLS.CertFile = filepath.Join(t.TempDir(), "bbs.crt")
+		config.TLS.KeyFile = filepath.Join(t.TempDir(), "bbs.key")
+		config.Timeouts.Login = 300 * time.Millisecond
+	})
+
+	// No SSH or TLS handshake nor HTTP request is ever started
+	for _, name := range []string{"ssh", "web", "tls"} {
+		conn, err := net.Dial("tcp", s.Addr(name).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(testTimeout))
+		buf := make([]byte, 256)
+		for err == nil {
+			_, err = conn.Read(buf)
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("%s connection still open after %s", name, testTimeout)
+		}
+	}
+}
+
+func TestIdleAwayAndLogout(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Timeouts.Away = 500 * time.Millisecond
+		config.Timeouts.Idle = 4 * time.Second
+	})
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	alice.expect("You will be logged out for being idle in 2 seconds unless you type something")
+	bob.send("users")
+	out := bob.expectPrompt()
+	if !strings.Contains(out, "alice (away)") || strings.Contains(out, "bob (away)") {
+		t.Errorf("users: %q", out)
+	}
+
+	// Typing something puts off the logout and ends being away
+	alice.send("users")
+	alice.expect("alice (you)")
+	bob.send("users")
+	out = bob.expectPrompt()
+	if strings.Contains(out, "alice (away)") {
+		t.Errorf("still away after typing: %q", out)
+	}
+
+	alice.expect("You will be logged out for being idle in")
+	alice.expect("are being logged out. Goodbye!")
+	alice.expectClosed()
+}
+
+func TestTelnetKeepalive(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Timeouts.Keepalive = 100 * time.Millisecond
+	})
+
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var received []byte
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+	for !bytes.Contains(received, []byte{telnetIAC, telnetNOP}) {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("no NOP probe: %v", err)
+		}
+		received = append(received, buf[:n]...)
+	}
+}
+
+func TestDeadlineReader(t *testing.T) {
+	lines := make(chan string)
+	r := newDeadlineReader(func() readResult {
+		return readResult{data: []byte(<-lines)}
+	})
+	defer r.close()
+
+	r.setDeadline(time.Now().Add(50 * time.Millisecond))
+	if result := r.next(); !errors.Is(result.err, os.ErrDeadlineExceeded) {
+		t.Fatalf("before the deadline: %+v", result)
+	}
+
+	// The stream is still usable after the deadline passed
+	r.setDeadline(time.Time{})
+	go func() { lines <- "hello" }()
+	if result := r.next(); result.err != nil || string(result.data) != "hello" {
+		t.Fatalf("after the deadline: %+v", result)
+	}
+}
@@ -0,0 +1,258 @@
+package storage
+
+import "time"
+
+// Board is a bulletin board. The counts are filled in by GetBoards for the
+// user asking.
+type Board struct {
+	ID          int
+	Name        string
+	Description string
+	CreatedAt   time.Time
+
+	Threads int
+	Posts   int
+	Unread  int
+}
+
+// Thread is a topic on a board. The counts and LastPostAt are filled in
+// for the user asking.
+type Thread struct {
+	ID         int
+	BoardID    int
+	BoardName  string
+	UserID     int
+	Author     string
+	Subject    string
+	CreatedAt  time.Time
+	Posts      int
+	Unread     int
+	LastPostAt time.Time
+}
+
+// Post is one message in a thread. BoardName and Subject are only filled
+// in by GetUnreadPosts.
+type Post struct {
+	ID        int
+	ThreadID  int
+	UserID    int
+	Author    string
+	Body      string
+	CreatedAt time.Time
+
+	BoardName string
+	Subject   string
+}
+
+func (d *Database) CreateBoard(name, description string) error {
+	_, err := d.exec("INSERT INTO boards (name, description) VALUES (?, ?) ON CONFLICT (name) DO NOTHING", name, description)
+	return err
+}
+
+// GetBoards lists every board by name with thread, post and unread counts
+// for userID.
+func (d *Database) GetBoards(userID int) ([]Board, error) {
+	rows, err := d.query(`
+		SELECT b.id, b.name, b.description, b.created_at,
+			(SELECT COUNT(*) FROM threads t WHERE t.board_id = b.id),
+			(SELECT COUNT(*) FROM posts p JOIN threads t ON t.id = p.thread_id WHERE t.board_id = b.id),
+			(SELECT COUNT(*) FROM posts p
+				JOIN threads t ON t.id = p.thread_id
+				LEFT JOIN board_reads r ON r.thread_id = t.id AND r.user_id = ?
+				WHERE t.board_id = b.id AND p.id > COALESCE(r.last_post_id, 0))
+		FROM boards b
+		ORDER BY b.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var boards []Board
+	for rows.Next() {
+		var board Board
+		if err := rows.Scan(&board.ID, &board.Name, &board.Description, &board.CreatedAt,
+			&board.Threads, &board.Posts, &board.Unread); err != nil {
+			return nil, err
+		}
+		boards = append(boards, board)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return boards, nil
+}
+
+// GetBoard finds a board by name, ignoring case.
+func (d *Database) GetBoard(name string) (*Board, error) {
+	var board Board
+	err := d.queryRow("SELECT id, name, description, created_at FROM boards WHERE LOWER(name) = LOWER(?)", name).
+		Scan(&board.ID, &board.Name, &board.Description, &board.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+// threadColumns selects a Thread with counts for the user bound to the
+// first placeholder.
+const threadColumns = `
+	SELECT t.id, t.board_id, b.name, t.user_id, u.username, t.subject, t.created_at,
+		(SELECT COUNT(*) FROM posts p WHERE p.thread_id = t.id),
+		(SELECT COUNT(*) FROM posts p WHERE p.thread_id = t.id AND p.id > COALESCE(r.last_post_id, 0)),
+		lp.created_at
+	FROM threads t
+	JOIN boards b ON b.id = t.board_id
+	JOIN users u ON u.id = t.user_id
+	JOIN posts lp ON lp.id = (SELECT MAX(p.id) FROM posts p WHERE p.thread_id = t.id)
+	LEFT JOIN board_reads r ON r.thread_id = t.id AND r.user_id = ?`
+
+func scanThread(scanner interface{ Scan(...interface{}) error }) (Thread, error) {
+	var thread Thread
+	err := scanner.Scan(&thread.ID, &thread.BoardID, &thread.BoardName, &thread.UserID, &thread.Author,
+		&thread.Subject, &thread.CreatedAt, &thread.Posts, &thread.Unread, &thread.LastPostAt)
+	return thread, err
+}
+
+// GetThreads returns up to limit threads of a board, skipping offset,
+// ordered by most recent post.
+func (d *Database) GetThreads(boardID, userID, limit, offset int) ([]Thread, error) {
+	rows, err := d.query(threadColumns+`
+		WHERE t.board_id = ?
+		ORDER BY lp.id DESC
+		LIMIT ? OFFSET ?`, userID, boardID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var threads []Thread
+	for rows.Next() {
+		thread, err := scanThread(rows)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+func (d *Database) GetThread(threadID, userID int) (*Thread, error) {
+	thread, err := scanThread(d.queryRow(threadColumns+" WHERE t.id = ?", userID, threadID))
+	if err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// CreateThread starts a thread with its first post and returns its ID.
+func (d *Database) CreateThread(boardID, userID int, subject, body string) (int, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var threadID int
+	err = tx.QueryRow(d.rebind("INSERT INTO threads (board_id, user_id, subject) VALUES (?, ?, ?) RETURNING id"),
+		boardID, userID, subject).Scan(&threadID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(d.rebind("INSERT INTO posts (thread_id, user_id, body) VALUES (?, ?, ?)"), threadID, userID, body); err != nil {
+		return 0, err
+	}
+
+	return threadID, tx.Commit()
+}
+
+// AddPost replies to a thread and returns the new post's ID.
+func (d *Database) AddPost(threadID, userID int, body string) (int, error) {
+	var postID int
+	err := d.queryRow("INSERT INTO posts (thread_id, user_id, body) VALUES (?, ?, ?) RETURNING id", threadID, userID, body).
+		Scan(&postID)
+	return postID, err
+}
+
+// GetPosts returns up to limit posts of a thread, oldest first, skipping
+// offset.
+func (d *Database) GetPosts(threadID, limit, offset int) ([]Post, error) {
+	rows, err := d.query(`
+		SELECT p.id, p.thread_id, p.user_id, u.username, p.body, p.created_at
+		FROM posts p JOIN users u ON u.id = p.user_id
+		WHERE p.thread_id = ?
+		ORDER BY p.id
+		LIMIT ? OFFSET ?`, threadID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		if err := rows.Scan(&post.ID, &post.ThreadID, &post.UserID, &post.Author, &post.Body, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// MarkThreadRead moves the user's read pointer in a thread forward to
+// postID. It never moves backwards.
+func (d *Database) MarkThreadRead(userID, threadID, postID int) error {
+	_, err := d.exec(`
+		INSERT INTO board_reads (user_id, thread_id, last_post_id) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, thread_id) DO UPDATE SET last_post_id = excluded.last_post_id
+		WHERE excluded.last_post_id > board_reads.last_post_id`, userID, threadID, postID)
+	return err
+}
+
+// GetUnreadPosts returns up to limit posts the user hasn't read yet across
+// all boards, grouped by board and thread.
+func (d *Database) GetUnreadPosts(userID, limit int) ([]Post, error) {
+	rows, err := d.query(`
+		SELECT p.id, p.thread_id, p.user_id, u.username, p.body, p.created_at, b.name, t.subject
+		FROM posts p
+		JOIN threads t ON t.id = p.thread_id
+		JOIN boards b ON b.id = t.board_id
+		JOIN users u ON u.id = p.user_id
+		LEFT JOIN board_reads r ON r.thread_id = t.id AND r.user_id = ?
+		WHERE p.id > COALESCE(r.last_post_id, 0)
+		ORDER BY b.name, t.id, p.id
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		if err := rows.Scan(&post.ID, &post.ThreadID, &post.UserID, &post.Author, &post.Body, &post.CreatedAt,
+			&post.BoardName, &post.Subject); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (d *Database) CountUnreadPosts(userID int) (int, error) {
+	var count int
+	err := d.queryRow(`
+		SELECT COUNT(*)
+		FROM posts p
+		LEFT JOIN board_reads r ON r.thread_id = p.thread_id AND r.user_id = ?
+		WHERE p.id > COALESCE(r.last_post_id, 0)`, userID).Scan(&count)
+	return count, err
+}
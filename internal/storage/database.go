@@ -0,0 +1,486 @@
+// Package storage is the persistence layer shared by the BBS server and the
+// admin tool. The same SQL runs on SQLite and PostgreSQL.
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SchemaVersion is the database layout this package reads and writes, the
+// number of the last file in each migrations/<driver> directory.
+const SchemaVersion = 13
+
+// Supported values for the driver argument of NewDatabase and Open
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory" // private SQLite :memory: database, gone on Close
+)
+
+// Database is the SQL implementation of Store. Queries are written with
+// "?" placeholders and rebound for the driver in use.
+type Database struct {
+	db     *sql.DB
+	driver string
+	search string // see SearchMode
+}
+
+type User struct {
+	ID       int
+	Username string
+	Password string
+	Role     string // RoleUser, RoleModerator or RoleSysop
+	JoinedAt time.Time
+	LastSeen time.Time
+}
+
+type ChatRoom struct {
+	ID          int
+	Name        string
+	Description string
+	Access      string // RoomPublic, RoomPrivate or RoomPassword
+	OwnerID     int    // 0 for rooms nobody owns
+	OwnerName   string
+	CreatedAt   time.Time
+	ArchivedAt  time.Time // zero unless archived (read-only)
+	Topic       string
+	TopicBy     string    // who set Topic
+	TopicAt     time.Time // zero if the topic was never set
+}
+
+type Message struct {
+	ID        int
+	RoomID    int
+	UserID    int
+	Username  string
+	Content   string
+	Timestamp time.Time
+	EditedAt  time.Time // zero unless edited
+}
+
+// messageColumns selects a Message from messages aliased as m, for
+// scanMessage.
+const messageColumns = "m.id, m.room_id, m.user_id, m.username, m.content, m.timestamp, m.edited_at"
+
+// scanMessage reads messageColumns followed by any extra columns.
+func scanMessage(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (Message, error) {
+	var msg Message
+	var editedAt sql.NullTime
+	dest := append([]interface{}{&msg.ID, &msg.RoomID, &msg.UserID, &msg.Username, &msg.Content, &msg.Timestamp, &editedAt}, extra...)
+	err := scanner.Scan(dest...)
+	msg.EditedAt = editedAt.Time
+	return msg, err
+}
+
+type MOTD struct {
+	ID        int
+	Content   string
+	UpdatedAt time.Time
+	UpdatedBy string
+}
+
+type UserKey struct {
+	ID          int
+	UserID      int
+	Fingerprint string
+	PublicKey   string
+	Comment     string
+	CreatedAt   time.Time
+}
+
+// NewDatabase connects to the database, creating an SQLite file if needed,
+// and applies any pending migrations. source is a file path for SQLite, a
+// connection string for PostgreSQL and ignored for memory.
+func NewDatabase(driver, source string) (*Database, error) {
+	database, err := connect(driver, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := database.Migrate(); err != nil {
+		database.Close()
+		return nil, err
+	}
+	if err := database.setupSearch(); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	return database, nil
+}
+
+// Open connects to an existing database without creating or migrating
+// anything. It fails if the database is missing or was written by a newer
+// build; callers should check PendingMigrations before relying on the
+// current schema.
+func Open(driver, source string) (*Database, error) {
+	if driver == DriverMemory {
+		return nil, fmt.Errorf("the memory driver has no existing database to open")
+	}
+	if driver == DriverSQLite {
+		if _, err := os.Stat(source); err != nil {
+			return nil, err
+		}
+	}
+
+	database, err := connect(driver, source)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := database.Version()
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+	if version > SchemaVersion {
+		database.Close()
+		return nil, fmt.Errorf("database has schema version %d, this build only understands up to %d", version, SchemaVersion)
+	}
+	if err := database.detectSearch(); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	return database, nil
+}
+
+func connect(driver, source string) (*Database, error) {
+	var sqlDriver string
+	switch driver {
+	case DriverSQLite:
+		sqlDriver = "sqlite3"
+	case DriverPostgres:
+		sqlDriver = "postgres"
+	case DriverMemory:
+		sqlDriver, source = "sqlite3", ":memory:"
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
+	db, err := sql.Open(sqlDriver, source)
+	if err != nil {
+		return nil, err
+	}
+	if driver == DriverMemory {
+		// Every new connection would get its own empty database, so keep
+		// exactly one open for the life of the store
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Database{db: db, driver: driver}, nil
+}
+
+// rebind rewrites "?" placeholders as $1, $2... for PostgreSQL.
+func (d *Database) rebind(query string) string {
+	if d.driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d *Database) exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.db.Exec(d.rebind(query), args...)
+}
+
+func (d *Database) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.Query(d.rebind(query), args...)
+}
+
+func (d *Database) queryRow(query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRow(d.rebind(query), args...)
+}
+
+// Seed creates the given chat rooms and boards if they don't exist yet and
+// sets the MOTD if the database doesn't have one.
+func (d *Database) Seed(rooms []ChatRoom, boards []Board, motd string) error {
+	for _, room := range rooms {
+		if err := d.CreateChatRoom(room.Name, room.Description); err != nil {
+			return err
+		}
+	}
+	for _, board := range boards {
+		if err := d.CreateBoard(board.Name, board.Description); err != nil {
+			return err
+		}
+	}
+
+	if _, err := d.GetMOTD(); err == sql.ErrNoRows {
+		return d.SetMOTD(motd, "System")
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateUser registers a new account. The first account in the database
+// becomes its sysop; everyone after is a plain user.
+func (d *Database) CreateUser(username, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.exec(`
+		INSERT INTO users (username, password, role)
+		SELECT ?, ?, CASE WHEN EXISTS (SELECT 1 FROM users) THEN ? ELSE ? END`,
+		username, string(hashedPassword), RoleUser, RoleSysop)
+	return err
+}
+
+func (d *Database) AuthenticateUser(username, password string) (*User, error) {
+	var user User
+	var hashedPassword string
+
+	err := d.queryRow("SELECT id, username, password, role, joined_at, last_seen FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username, &hashedPassword, &user.Role, &user.JoinedAt, &user.LastSeen)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		return nil, err
+	}
+
+	// Update last seen
+	d.exec("UPDATE users SET last_seen = CURRENT_TIMESTAMP WHERE id = ?", user.ID)
+
+	return &user, nil
+}
+
+// GetUserByName looks up a user without authenticating. The password hash
+// is left empty.
+func (d *Database) GetUserByName(username string) (*User, error) {
+	var user User
+	err := d.queryRow("SELECT id, username, role, joined_at, last_seen FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username, &user.Role, &user.JoinedAt, &user.LastSeen)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns every registered user ordered by name. Password hashes
+// are left empty.
+func (d *Database) ListUsers() ([]User, error) {
+	rows, err := d.query("SELECT id, username, role, joined_at, last_seen FROM users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role, &user.JoinedAt, &user.LastSeen); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (d *Database) AddUserKey(userID int, fingerprint, publicKey, comment string) error {
+	_, err := d.exec("INSERT INTO user_keys (user_id, fingerprint, public_key, comment) VALUES (?, ?, ?, ?)",
+		userID, fingerprint, publicKey, comment)
+	return err
+}
+
+func (d *Database) GetUserKeys(userID int) ([]UserKey, error) {
+	rows, err := d.query("SELECT id, user_id, fingerprint, public_key, comment, created_at FROM user_keys WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []UserKey
+	for rows.Next() {
+		var key UserKey
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Fingerprint, &key.PublicKey, &key.Comment, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (d *Database) DeleteUserKey(userID, keyID int) error {
+	result, err := d.exec("DELETE FROM user_keys WHERE id = ? AND user_id = ?", keyID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserByKey looks up the owner of an SSH key fingerprint. Offering a
+// key isn't a login yet, so unlike AuthenticateUser it leaves last_seen
+// alone; see UpdateLastSeen.
+func (d *Database) GetUserByKey(fingerprint string) (*User, error) {
+	var user User
+	err := d.queryRow(`
+		SELECT u.id, u.username, u.role, u.joined_at, u.last_seen
+		FROM users u JOIN user_keys k ON k.user_id = u.id
+		WHERE k.fingerprint = ?`, fingerprint).
+		Scan(&user.ID, &user.Username, &user.Role, &user.JoinedAt, &user.LastSeen)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateLastSeen records a login that didn't go through AuthenticateUser.
+func (d *Database) UpdateLastSeen(userID int) error {
+	result, err := d.exec("UPDATE users SET last_seen = CURRENT_TIMESTAMP WHERE id = ?", userID)
+	return requireRow(result, err)
+}
+
+// roomColumns selects a ChatRoom for scanRoom, as r.
+const roomColumns = `
+	SELECT r.id, r.name, r.description, r.access, r.owner_id, o.username, r.created_at, r.archived_at,
+		r.topic, t.username, r.topic_at
+	FROM chat_rooms r
+	LEFT JOIN users o ON o.id = r.owner_id
+	LEFT JOIN users t ON t.id = r.topic_by`
+
+func scanRoom(scanner interface{ Scan(...interface{}) error }) (ChatRoom, error) {
+	var room ChatRoom
+	var ownerID sql.NullInt64
+	var ownerName, topicBy sql.NullString
+	var archivedAt, topicAt sql.NullTime
+	err := scanner.Scan(&room.ID, &room.Name, &room.Description, &room.Access, &ownerID, &ownerName, &room.CreatedAt, &archivedAt,
+		&room.Topic, &topicBy, &topicAt)
+	room.OwnerID, room.OwnerName = int(ownerID.Int64), ownerName.String
+	room.ArchivedAt = archivedAt.Time
+	room.TopicBy, room.TopicAt = topicBy.String, topicAt.Time
+	return room, err
+}
+
+func (d *Database) GetChatRooms() ([]ChatRoom, error) {
+	rows, err := d.query(roomColumns + " ORDER BY r.name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []ChatRoom
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (d *Database) CreateChatRoom(name, description string) error {
+	_, err := d.exec("INSERT INTO chat_rooms (name, description) VALUES (?, ?) ON CONFLICT (name) DO NOTHING", name, description)
+	return err
+}
+
+func (d *Database) GetChatRoom(name string) (*ChatRoom, error) {
+	room, err := scanRoom(d.queryRow(roomColumns+" WHERE r.name = ?", name))
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// AddMessage posts to a chat room. It returns ErrRoomArchived if the room
+// is archived or has been deleted.
+func (d *Database) AddMessage(roomID, userID int, username, content string) error {
+	result, err := d.exec(`
+		INSERT INTO messages (room_id, user_id, username, content)
+		SELECT id, ?, ?, ? FROM chat_rooms WHERE id = ? AND archived_at IS NULL`,
+		userID, username, content, roomID)
+	if err := requireRow(result, err); err == sql.ErrNoRows {
+		return ErrRoomArchived
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Database) GetRecentMessages(roomID int, limit int) ([]Message, error) {
+	rows, err := d.query(`
+		SELECT `+messageColumns+`
+		FROM messages m
+		WHERE m.room_id = ? AND m.deleted_at IS NULL
+		ORDER BY m.timestamp DESC, m.id DESC
+		LIMIT ?`, roomID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append([]Message{msg}, messages...) // Reverse order for chronological display
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (d *Database) GetMOTD() (*MOTD, error) {
+	var motd MOTD
+	err := d.queryRow("SELECT id, content, updated_at, updated_by FROM motd ORDER BY updated_at DESC, id DESC LIMIT 1").
+		Scan(&motd.ID, &motd.Content, &motd.UpdatedAt, &motd.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &motd, nil
+}
+
+func (d *Database) SetMOTD(content, updatedBy string) error {
+	_, err := d.exec("INSERT INTO motd (content, updated_by) VALUES (?, ?)", content, updatedBy)
+	return err
+}
+
+func (d *Database) Close() error {
+	return d.db.Close()
+}
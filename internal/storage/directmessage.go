@@ -0,0 +1,101 @@
+package storage
+
+import "time"
+
+// DirectMessage is a private message between two users.
+type DirectMessage struct {
+	ID        int
+	FromID    int
+	FromName  string
+	ToID      int
+	ToName    string
+	Content   string
+	Timestamp time.Time
+}
+
+// Correspondent summarises a user's conversation with one other user.
+type Correspondent struct {
+	UserID   int
+	Username string
+	Messages int
+	LastAt   time.Time
+}
+
+func (d *Database) AddDirectMessage(fromID, toID int, content string) error {
+	_, err := d.exec("INSERT INTO direct_messages (from_id, to_id, content) VALUES (?, ?, ?)", fromID, toID, content)
+	return err
+}
+
+// GetDirectMessages returns up to limit messages exchanged between two
+// users, skipping the newest offset, in chronological order.
+func (d *Database) GetDirectMessages(userID, otherID, limit, offset int) ([]DirectMessage, error) {
+	rows, err := d.query(`
+		SELECT m.id, m.from_id, f.username, m.to_id, t.username, m.content, m.timestamp
+		FROM direct_messages m
+		JOIN users f ON f.id = m.from_id
+		JOIN users t ON t.id = m.to_id
+		WHERE (m.from_id = ? AND m.to_id = ?) OR (m.from_id = ? AND m.to_id = ?)
+		ORDER BY m.id DESC
+		LIMIT ? OFFSET ?`, userID, otherID, otherID, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []DirectMessage
+	for rows.Next() {
+		var msg DirectMessage
+		if err := rows.Scan(&msg.ID, &msg.FromID, &msg.FromName, &msg.ToID, &msg.ToName, &msg.Content, &msg.Timestamp); err != nil {
+			return nil, err
+		}
+		messages = append([]DirectMessage{msg}, messages...) // Reverse order for chronological display
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// CountDirectMessages returns how many messages two users have exchanged.
+func (d *Database) CountDirectMessages(userID, otherID int) (int, error) {
+	var count int
+	err := d.queryRow(`
+		SELECT COUNT(*) FROM direct_messages
+		WHERE (from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)`,
+		userID, otherID, otherID, userID).Scan(&count)
+	return count, err
+}
+
+// GetCorrespondents lists everyone the user has exchanged direct messages
+// with, most recent conversation first.
+func (d *Database) GetCorrespondents(userID int) ([]Correspondent, error) {
+	rows, err := d.query(`
+		SELECT u.id, u.username, c.messages, m.timestamp
+		FROM (
+			SELECT CASE WHEN from_id = ? THEN to_id ELSE from_id END AS other_id,
+				COUNT(*) AS messages, MAX(id) AS last_id
+			FROM direct_messages
+			WHERE from_id = ? OR to_id = ?
+			GROUP BY CASE WHEN from_id = ? THEN to_id ELSE from_id END
+		) c
+		JOIN direct_messages m ON m.id = c.last_id
+		JOIN users u ON u.id = c.other_id
+		ORDER BY c.last_id DESC`, userID, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var correspondents []Correspondent
+	for rows.Next() {
+		var c Correspondent
+		if err := rows.Scan(&c.UserID, &c.Username, &c.Messages, &c.LastAt); err != nil {
+			return nil, err
+		}
+		correspondents = append(correspondents, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return correspondents, nil
+}
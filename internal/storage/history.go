@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// HistoryQuery selects messages from one room for GetHistory. Zero-valued
+// filters match everything.
+type HistoryQuery struct {
+	RoomID   int
+	BeforeID int       // only messages older than this one
+	UserID   int       // only messages by this user
+	Since    time.Time // only messages at or after this time
+	Until    time.Time // only messages before this time
+}
+
+// GetHistory returns the newest limit messages matching q, oldest first.
+// Pass the ID of the first message returned as q.BeforeID to get the page
+// before it.
+func (d *Database) GetHistory(q HistoryQuery, limit int) ([]Message, error) {
+	where := []string{"m.room_id = ?", "m.deleted_at IS NULL"}
+	args := []interface{}{q.RoomID}
+	if q.BeforeID > 0 {
+		where = append(where, "m.id < ?")
+		args = append(args, q.BeforeID)
+	}
+	if q.UserID > 0 {
+		where = append(where, "m.user_id = ?")
+		args = append(args, q.UserID)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "m.timestamp >= ?")
+		args = append(args, d.timeArg(q.Since))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "m.timestamp < ?")
+		args = append(args, d.timeArg(q.Until))
+	}
+	args = append(args, limit)
+
+	rows, err := d.query(`
+		SELECT `+messageColumns+`
+		FROM messages m
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY m.id DESC
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// timeArg converts t for comparison with a timestamp column. SQLite keeps
+// CURRENT_TIMESTAMP as UTC text, so it gets text in the same format.
+func (d *Database) timeArg(t time.Time) interface{} {
+	if d.driver == DriverPostgres {
+		return t
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
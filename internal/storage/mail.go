@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Mail is a message left in a member's inbox.
+type Mail struct {
+	ID       int
+	FromID   int
+	FromName string
+	ToID     int
+	ToName   string
+	Subject  string
+	Body     string
+	SentAt   time.Time
+	Read     bool
+}
+
+func (d *Database) SendMail(fromID, toID int, subject, body string) error {
+	_, err := d.exec("INSERT INTO mail (from_id, to_id, subject, body) VALUES (?, ?, ?, ?)", fromID, toID, subject, body)
+	return err
+}
+
+const mailColumns = `
+	SELECT m.id, m.from_id, f.username, m.to_id, t.username, m.subject, m.body, m.sent_at, m.read_at
+	FROM mail m
+	JOIN users f ON f.id = m.from_id
+	JOIN users t ON t.id = m.to_id`
+
+func scanMail(scanner interface{ Scan(...interface{}) error }) (Mail, error) {
+	var mail Mail
+	var readAt sql.NullTime
+	err := scanner.Scan(&mail.ID, &mail.FromID, &mail.FromName, &mail.ToID, &mail.ToName,
+		&mail.Subject, &mail.Body, &mail.SentAt, &readAt)
+	mail.Read = readAt.Valid
+	return mail, err
+}
+
+// GetInbox returns every message addressed to the user, newest first.
+func (d *Database) GetInbox(userID int) ([]Mail, error) {
+	rows, err := d.query(mailColumns+" WHERE m.to_id = ? ORDER BY m.id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inbox []Mail
+	for rows.Next() {
+		mail, err := scanMail(rows)
+		if err != nil {
+			return nil, err
+		}
+		inbox = append(inbox, mail)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return inbox, nil
+}
+
+// GetMail returns one message from the user's inbox, or sql.ErrNoRows if
+// it doesn't exist or belongs to someone else.
+func (d *Database) GetMail(userID, mailID int) (*Mail, error) {
+	mail, err := scanMail(d.queryRow(mailColumns+" WHERE m.id = ? AND m.to_id = ?", mailID, userID))
+	if err != nil {
+		return nil, err
+	}
+	return &mail, nil
+}
+
+func (d *Database) MarkMailRead(userID, mailID int) error {
+	_, err := d.exec("UPDATE mail SET read_at = CURRENT_TIMESTAMP WHERE id = ? AND to_id = ? AND read_at IS NULL", mailID, userID)
+	return err
+}
+
+func (d *Database) DeleteMail(userID, mailID int) error {
+	result, err := d.exec("DELETE FROM mail WHERE id = ? AND to_id = ?", mailID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (d *Database) CountUnreadMail(userID int) (int, error) {
+	var count int
+	err := d.queryRow("SELECT COUNT(*) FROM mail WHERE to_id = ? AND read_at IS NULL", userID).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Up-migrations named migrations/<driver>/NNNN_description.sql, applied in
+// order. Every driver has the same versions. Never edit one that has been
+// released; add a new file instead.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// Migration is one schema change taking the database to Version.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns every embedded migration for the database's driver in
+// version order.
+func (d *Database) Migrations() ([]Migration, error) {
+	if d.driver == DriverMemory {
+		return driverMigrations(DriverSQLite)
+	}
+	return driverMigrations(d.driver)
+}
+
+func driverMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		number, description, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_description.sql", entry.Name())
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: description, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("%s migration versions must run 1, 2, 3...; found %d at position %d", driver, migration.Version, i+1)
+		}
+	}
+	if len(migrations) != SchemaVersion {
+		return nil, fmt.Errorf("SchemaVersion is %d but there are %d %s migrations", SchemaVersion, len(migrations), driver)
+	}
+
+	return migrations, nil
+}
+
+// Version returns the schema version recorded in the database, or 0 for an
+// empty database or one created before versions were recorded.
+func (d *Database) Version() (int, error) {
+	tableQuery := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'"
+	if d.driver == DriverPostgres {
+		tableQuery = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_version'"
+	}
+
+	var tables int
+	err := d.queryRow(tableQuery).Scan(&tables)
+	if err != nil || tables == 0 {
+		return 0, err
+	}
+
+	var version int
+	err = d.queryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// PendingMigrations returns the migrations not yet applied to the database.
+func (d *Database) PendingMigrations() ([]Migration, error) {
+	migrations, err := d.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := d.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("database has schema version %d, this build only understands up to %d", version, SchemaVersion)
+	}
+
+	return migrations[version:], nil
+}
+
+// Migrate applies every pending migration, each in its own transaction, and
+// returns the ones it applied.
+func (d *Database) Migrate() ([]Migration, error) {
+	pending, err := d.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.exec("CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)"); err != nil {
+		return nil, err
+	}
+
+	for i, migration := range pending {
+		if err := d.apply(migration); err != nil {
+			return pending[:i], fmt.Errorf("migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+func (d *Database) apply(migration Migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM schema_version"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.rebind("INSERT INTO schema_version (version) VALUES (?)"), migration.Version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// baselineDB writes a database in the original release's layout and
+// returns its path.
+func baselineDB(t *testing.T) string {
+	t.Helper()
+
+	fixture, err := os.ReadFile("testdata/baseline.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bbs.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(fixture)); err != nil {
+		t.Fatalf("loading fixture: %v", err)
+	}
+	return path
+}
+
+func TestMigrationsAreContiguous(t *testing.T) {
+	for _, driver := range []string{DriverSQLite, DriverPostgres} {
+		migrations, err := driverMigrations(driver)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		if got := migrations[len(migrations)-1].Version; got != SchemaVersion {
+			t.Errorf("%s: last migration is %d, SchemaVersion is %d", driver, got, SchemaVersion)
+		}
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	path := baselineDB(t)
+
+	db, err := Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.Version(); err != nil || version != 0 {
+		t.Fatalf("baseline version = %d, %v; want 0", version, err)
+	}
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != SchemaVersion {
+		t.Errorf("%d pending migrations, want %d", len(pending), SchemaVersion)
+	}
+	db.Close()
+
+	db, err = NewDatabase(DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("migrating baseline: %v", err)
+	}
+	defer db.Close()
+
+	if version, err := db.Version(); err != nil || version != SchemaVersion {
+		t.Fatalf("version after migrate = %d, %v; want %d", version, err, SchemaVersion)
+	}
+
+	// Existing data survives
+	users, err := db.ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Username != "alice" || users[1].Username != "bob" {
+		t.Errorf("users after migrate = %+v", users)
+	}
+	// The oldest account becomes sysop
+	if len(users) == 2 && (users[0].Role != RoleSysop || users[1].Role != RoleUser) {
+		t.Errorf("roles after migrate: alice %q, bob %q", users[0].Role, users[1].Role)
+	}
+	room, err := db.GetChatRoom("General")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := db.GetRecentMessages(room.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Content != "hello" || messages[1].Content != "hi alice" {
+		t.Errorf("messages after migrate = %+v", messages)
+	}
+	if motd, err := db.GetMOTD(); err != nil || motd.Content != "Welcome!" {
+		t.Errorf("MOTD after migrate = %+v, %v", motd, err)
+	}
+
+	// Tables added by later migrations work
+	if err := db.AddUserKey(users[0].ID, "SHA256:test", "ssh-ed25519 AAAA", "laptop"); err != nil {
+		t.Fatalf("user_keys after migrate: %v", err)
+	}
+	if user, err := db.GetUserByKey("SHA256:test"); err != nil || user.Username != "alice" {
+		t.Errorf("GetUserByKey = %+v, %v", user, err)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bbs.db")
+
+	db, err := NewDatabase(DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateUser("carol", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := db.Migrate()
+	if err != nil || len(applied) != 0 {
+		t.Errorf("second Migrate applied %d, %v; want none", len(applied), err)
+	}
+	db.Close()
+
+	db, err = NewDatabase(DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.AuthenticateUser("carol", "secret"); err != nil {
+		t.Errorf("user lost on reopen: %v", err)
+	}
+}
+
+func TestOpenDoesNotMigrate(t *testing.T) {
+	path := baselineDB(t)
+
+	db, err := Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.PendingMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if version, _ := db.Version(); version != 0 {
+		t.Errorf("Open changed the schema version to %d", version)
+	}
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bbs.db")
+
+	db, err := NewDatabase(DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.db.Exec("UPDATE schema_version SET version = ?", SchemaVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if db, err := Open(DriverSQLite, path); err == nil {
+		db.Close()
+		t.Error("Open accepted a newer schema")
+	}
+	if db, err := NewDatabase(DriverSQLite, path); err == nil {
+		db.Close()
+		t.Error("NewDatabase accepted a newer schema")
+	}
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.db")
+	if db, err := Open(DriverSQLite, path); err == nil {
+		db.Close()
+		t.Fatal("Open created a missing database")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Open left a file behind: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+-- SSH public keys for key-based login.
+
+CREATE TABLE IF NOT EXISTS user_keys (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	fingerprint TEXT UNIQUE NOT NULL,
+	public_key TEXT NOT NULL,
+	comment TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
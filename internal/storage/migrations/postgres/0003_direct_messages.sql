@@ -0,0 +1,12 @@
+-- Private messages between two users.
+
+CREATE TABLE direct_messages (
+	id SERIAL PRIMARY KEY,
+	from_id INTEGER NOT NULL REFERENCES users(id),
+	to_id INTEGER NOT NULL REFERENCES users(id),
+	content TEXT NOT NULL,
+	timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX direct_messages_from_to ON direct_messages (from_id, to_id, id);
+CREATE INDEX direct_messages_to_from ON direct_messages (to_id, from_id, id);
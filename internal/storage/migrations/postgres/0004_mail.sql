@@ -0,0 +1,14 @@
+-- Offline mail between members. read_at stays NULL until the recipient
+-- opens the message.
+
+CREATE TABLE mail (
+	id SERIAL PRIMARY KEY,
+	from_id INTEGER NOT NULL REFERENCES users(id),
+	to_id INTEGER NOT NULL REFERENCES users(id),
+	subject TEXT NOT NULL,
+	body TEXT NOT NULL,
+	sent_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	read_at TIMESTAMPTZ
+);
+
+CREATE INDEX mail_to ON mail (to_id, id);
@@ -0,0 +1,35 @@
+-- Bulletin boards: boards hold threads, threads hold posts. board_reads
+-- remembers the last post each user has read in each thread.
+
+CREATE TABLE boards (
+	id SERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE threads (
+	id SERIAL PRIMARY KEY,
+	board_id INTEGER NOT NULL REFERENCES boards(id),
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	subject TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE posts (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL REFERENCES threads(id),
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	body TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE board_reads (
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	thread_id INTEGER NOT NULL REFERENCES threads(id),
+	last_post_id INTEGER NOT NULL,
+	PRIMARY KEY (user_id, thread_id)
+);
+
+CREATE INDEX threads_board ON threads (board_id, id);
+CREATE INDEX posts_thread ON posts (thread_id, id);
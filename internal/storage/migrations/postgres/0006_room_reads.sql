@@ -0,0 +1,11 @@
+-- room_reads remembers the newest chat message each user has seen in each
+-- room, so joining a room can catch up on what was missed.
+
+CREATE TABLE room_reads (
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	room_id INTEGER NOT NULL REFERENCES chat_rooms(id),
+	last_message_id INTEGER NOT NULL,
+	PRIMARY KEY (user_id, room_id)
+);
+
+CREATE INDEX messages_room ON messages (room_id, id);
@@ -0,0 +1,7 @@
+-- Full-text search over chat messages. The generated column keeps itself
+-- up to date; 'simple' avoids stemming and stop words so any language works.
+
+ALTER TABLE messages ADD COLUMN search tsvector
+	GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX messages_search ON messages USING GIN (search);
@@ -0,0 +1,16 @@
+-- Messages can be edited or deleted by their author. Deleted messages keep
+-- their row, emptied, so IDs stay stable; message_revisions holds every
+-- earlier version for moderators.
+
+ALTER TABLE messages ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE TABLE message_revisions (
+	id SERIAL PRIMARY KEY,
+	message_id INTEGER NOT NULL REFERENCES messages(id),
+	action TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX message_revisions_message ON message_revisions (message_id, id);
@@ -0,0 +1,6 @@
+-- Every account has a role: user, moderator or sysop. The oldest account
+-- of an existing database becomes its sysop.
+
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+
+UPDATE users SET role = 'sysop' WHERE id = (SELECT MIN(id) FROM users);
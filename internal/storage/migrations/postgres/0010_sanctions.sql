@@ -0,0 +1,20 @@
+-- Moderation history: kicks, mutes and bans with who issued them, why and
+-- until when. A mute without a room applies everywhere; a ban matches by
+-- user, by IP address or both. Lifting keeps the row.
+
+CREATE TABLE sanctions (
+	id SERIAL PRIMARY KEY,
+	kind TEXT NOT NULL,
+	user_id INTEGER REFERENCES users(id),
+	ip TEXT,
+	room_id INTEGER REFERENCES chat_rooms(id),
+	reason TEXT NOT NULL DEFAULT '',
+	issued_by INTEGER NOT NULL REFERENCES users(id),
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMPTZ,
+	lifted_at TIMESTAMPTZ,
+	lifted_by INTEGER REFERENCES users(id)
+);
+
+CREATE INDEX sanctions_user ON sanctions (user_id, kind);
+CREATE INDEX sanctions_ip ON sanctions (ip, kind);
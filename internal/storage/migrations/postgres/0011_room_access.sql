@@ -0,0 +1,15 @@
+-- Rooms can be public, private (members only) or password-protected, and
+-- may have an owner who manages who else gets in. Existing rooms stay
+-- public and ownerless. The password is a bcrypt hash.
+
+ALTER TABLE chat_rooms ADD COLUMN access TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE chat_rooms ADD COLUMN password TEXT;
+ALTER TABLE chat_rooms ADD COLUMN owner_id INTEGER REFERENCES users(id);
+
+CREATE TABLE room_members (
+	room_id INTEGER NOT NULL REFERENCES chat_rooms(id),
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	invited_by INTEGER REFERENCES users(id),
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (room_id, user_id)
+);
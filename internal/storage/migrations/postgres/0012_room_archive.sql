@@ -0,0 +1,3 @@
+-- Archived rooms keep their history but take no new messages.
+
+ALTER TABLE chat_rooms ADD COLUMN archived_at TIMESTAMPTZ;
@@ -0,0 +1,26 @@
+-- Rooms have a topic alongside their description. The current one is kept
+-- on chat_rooms with who set it and when; room_topics keeps every change.
+-- pinned_messages are shown to everyone joining the room.
+
+ALTER TABLE chat_rooms ADD COLUMN topic TEXT NOT NULL DEFAULT '';
+ALTER TABLE chat_rooms ADD COLUMN topic_by INTEGER REFERENCES users(id);
+ALTER TABLE chat_rooms ADD COLUMN topic_at TIMESTAMPTZ;
+
+CREATE TABLE room_topics (
+	id SERIAL PRIMARY KEY,
+	room_id INTEGER NOT NULL REFERENCES chat_rooms(id),
+	topic TEXT NOT NULL,
+	set_by INTEGER NOT NULL REFERENCES users(id),
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX room_topics_room ON room_topics (room_id, id);
+
+CREATE TABLE pinned_messages (
+	message_id INTEGER PRIMARY KEY REFERENCES messages(id),
+	room_id INTEGER NOT NULL REFERENCES chat_rooms(id),
+	pinned_by INTEGER NOT NULL REFERENCES users(id),
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX pinned_messages_room ON pinned_messages (room_id);
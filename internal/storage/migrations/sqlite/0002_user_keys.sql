@@ -0,0 +1,12 @@
+-- SSH public keys for key-based login. Databases from before migrations
+-- may already have the table.
+
+CREATE TABLE IF NOT EXISTS user_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	fingerprint TEXT UNIQUE NOT NULL,
+	public_key TEXT NOT NULL,
+	comment TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
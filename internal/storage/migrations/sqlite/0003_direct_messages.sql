@@ -0,0 +1,14 @@
+-- Private messages between two users.
+
+CREATE TABLE direct_messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	from_id INTEGER NOT NULL,
+	to_id INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (from_id) REFERENCES users(id),
+	FOREIGN KEY (to_id) REFERENCES users(id)
+);
+
+CREATE INDEX direct_messages_from_to ON direct_messages (from_id, to_id, id);
+CREATE INDEX direct_messages_to_from ON direct_messages (to_id, from_id, id);
@@ -0,0 +1,16 @@
+-- Offline mail between members. read_at stays NULL until the recipient
+-- opens the message.
+
+CREATE TABLE mail (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	from_id INTEGER NOT NULL,
+	to_id INTEGER NOT NULL,
+	subject TEXT NOT NULL,
+	body TEXT NOT NULL,
+	sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	read_at DATETIME,
+	FOREIGN KEY (from_id) REFERENCES users(id),
+	FOREIGN KEY (to_id) REFERENCES users(id)
+);
+
+CREATE INDEX mail_to ON mail (to_id, id);
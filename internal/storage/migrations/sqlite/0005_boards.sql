@@ -0,0 +1,41 @@
+-- Bulletin boards: boards hold threads, threads hold posts. board_reads
+-- remembers the last post each user has read in each thread.
+
+CREATE TABLE boards (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE threads (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	board_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	subject TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (board_id) REFERENCES boards(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE posts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	thread_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	body TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (thread_id) REFERENCES threads(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE board_reads (
+	user_id INTEGER NOT NULL,
+	thread_id INTEGER NOT NULL,
+	last_post_id INTEGER NOT NULL,
+	PRIMARY KEY (user_id, thread_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (thread_id) REFERENCES threads(id)
+);
+
+CREATE INDEX threads_board ON threads (board_id, id);
+CREATE INDEX posts_thread ON posts (thread_id, id);
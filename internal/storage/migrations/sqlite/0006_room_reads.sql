@@ -0,0 +1,13 @@
+-- room_reads remembers the newest chat message each user has seen in each
+-- room, so joining a room can catch up on what was missed.
+
+CREATE TABLE room_reads (
+	user_id INTEGER NOT NULL,
+	room_id INTEGER NOT NULL,
+	last_message_id INTEGER NOT NULL,
+	PRIMARY KEY (user_id, room_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (room_id) REFERENCES chat_rooms(id)
+);
+
+CREATE INDEX messages_room ON messages (room_id, id);
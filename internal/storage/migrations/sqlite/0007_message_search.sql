@@ -0,0 +1,5 @@
+-- Full-text search over chat messages uses an FTS5 table, but FTS5 is only
+-- compiled in with the sqlite_fts5 build tag, so the index and its triggers
+-- are created at startup when available (see search.go). Without it,
+-- searches fall back to LIKE and this migration has nothing to do.
+SELECT 1;
@@ -0,0 +1,17 @@
+-- Messages can be edited or deleted by their author. Deleted messages keep
+-- their row, emptied, so IDs stay stable; message_revisions holds every
+-- earlier version for moderators.
+
+ALTER TABLE messages ADD COLUMN edited_at DATETIME;
+ALTER TABLE messages ADD COLUMN deleted_at DATETIME;
+
+CREATE TABLE message_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (message_id) REFERENCES messages(id)
+);
+
+CREATE INDEX message_revisions_message ON message_revisions (message_id, id);
@@ -0,0 +1,24 @@
+-- Moderation history: kicks, mutes and bans with who issued them, why and
+-- until when. A mute without a room applies everywhere; a ban matches by
+-- user, by IP address or both. Lifting keeps the row.
+
+CREATE TABLE sanctions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	user_id INTEGER,
+	ip TEXT,
+	room_id INTEGER,
+	reason TEXT NOT NULL DEFAULT '',
+	issued_by INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME,
+	lifted_at DATETIME,
+	lifted_by INTEGER,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (room_id) REFERENCES chat_rooms(id),
+	FOREIGN KEY (issued_by) REFERENCES users(id),
+	FOREIGN KEY (lifted_by) REFERENCES users(id)
+);
+
+CREATE INDEX sanctions_user ON sanctions (user_id, kind);
+CREATE INDEX sanctions_ip ON sanctions (ip, kind);
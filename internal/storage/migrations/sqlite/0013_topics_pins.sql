@@ -0,0 +1,31 @@
+-- Rooms have a topic alongside their description. The current one is kept
+-- on chat_rooms with who set it and when; room_topics keeps every change.
+-- pinned_messages are shown to everyone joining the room.
+
+ALTER TABLE chat_rooms ADD COLUMN topic TEXT NOT NULL DEFAULT '';
+ALTER TABLE chat_rooms ADD COLUMN topic_by INTEGER REFERENCES users(id);
+ALTER TABLE chat_rooms ADD COLUMN topic_at DATETIME;
+
+CREATE TABLE room_topics (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	room_id INTEGER NOT NULL,
+	topic TEXT NOT NULL,
+	set_by INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (room_id) REFERENCES chat_rooms(id),
+	FOREIGN KEY (set_by) REFERENCES users(id)
+);
+
+CREATE INDEX room_topics_room ON room_topics (room_id, id);
+
+CREATE TABLE pinned_messages (
+	message_id INTEGER PRIMARY KEY,
+	room_id INTEGER NOT NULL,
+	pinned_by INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (message_id) REFERENCES messages(id),
+	FOREIGN KEY (room_id) REFERENCES chat_rooms(id),
+	FOREIGN KEY (pinned_by) REFERENCES users(id)
+);
+
+CREATE INDEX pinned_messages_room ON pinned_messages (room_id);
@@ -0,0 +1,57 @@
+package storage
+
+import "time"
+
+// PinnedMessage is a message pinned to the top of its room.
+type PinnedMessage struct {
+	Message
+	PinnedBy string
+	PinnedAt time.Time
+}
+
+// PinMessage pins a message in its room. Pinning it again does nothing.
+// It returns sql.ErrNoRows if the message doesn't exist or was deleted.
+func (d *Database) PinMessage(messageID, userID int) error {
+	msg, err := d.GetMessage(messageID)
+	if err != nil {
+		return err
+	}
+	_, err = d.exec(`
+		INSERT INTO pinned_messages (message_id, room_id, pinned_by) VALUES (?, ?, ?)
+		ON CONFLICT (message_id) DO NOTHING`, msg.ID, msg.RoomID, userID)
+	return err
+}
+
+// UnpinMessage takes a pin down. It returns sql.ErrNoRows if the message
+// wasn't pinned.
+func (d *Database) UnpinMessage(messageID int) error {
+	result, err := d.exec("DELETE FROM pinned_messages WHERE message_id = ?", messageID)
+	return requireRow(result, err)
+}
+
+// GetPinnedMessages returns the live pinned messages of a room in the
+// order they were pinned.
+func (d *Database) GetPinnedMessages(roomID int) ([]PinnedMessage, error) {
+	rows, err := d.query(`
+		SELECT `+messageColumns+`, u.username, p.created_at
+		FROM pinned_messages p
+		JOIN messages m ON m.id = p.message_id
+		JOIN users u ON u.id = p.pinned_by
+		WHERE p.room_id = ? AND m.deleted_at IS NULL
+		ORDER BY p.created_at, p.message_id`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pins []PinnedMessage
+	for rows.Next() {
+		var p PinnedMessage
+		p.Message, err = scanMessage(rows, &p.PinnedBy, &p.PinnedAt)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	return pins, rows.Err()
+}
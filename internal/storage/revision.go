@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// What a Revision records happening to a message
+const (
+	RevisionEdit   = "edit"
+	RevisionDelete = "delete"
+)
+
+// Revision is a message's content as it was before an edit or delete.
+type Revision struct {
+	ID        int
+	MessageID int
+	Action    string
+	Content   string
+	CreatedAt time.Time
+}
+
+// GetMessage returns a message that hasn't been deleted.
+func (d *Database) GetMessage(messageID int) (*Message, error) {
+	msg, err := scanMessage(d.queryRow("SELECT "+messageColumns+" FROM messages m WHERE m.id = ? AND m.deleted_at IS NULL", messageID))
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// GetMessageRoom returns the room a message was posted in, whether or not
+// the message has since been deleted.
+func (d *Database) GetMessageRoom(messageID int) (*ChatRoom, error) {
+	room, err := scanRoom(d.queryRow(roomColumns+" WHERE r.id = (SELECT room_id FROM messages WHERE id = ?)", messageID))
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// GetLastMessage returns the user's newest message in a room that hasn't
+// been deleted.
+func (d *Database) GetLastMessage(roomID, userID int) (*Message, error) {
+	msg, err := scanMessage(d.queryRow(`
+		SELECT `+messageColumns+`
+		FROM messages m
+		WHERE m.room_id = ? AND m.user_id = ? AND m.deleted_at IS NULL
+		ORDER BY m.id DESC
+		LIMIT 1`, roomID, userID))
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// EditMessage replaces a message's content, keeping the old content as a
+// revision. It returns ErrRoomArchived if the message's room is archived.
+func (d *Database) EditMessage(messageID int, content string) error {
+	return d.revise(messageID, RevisionEdit,
+		"UPDATE messages SET content = ?, edited_at = CURRENT_TIMESTAMP WHERE id = ?", content, messageID)
+}
+
+// DeleteMessage empties a message and marks it deleted, keeping the old
+// content as a revision. It returns ErrRoomArchived if the message's room
+// is archived.
+func (d *Database) DeleteMessage(messageID int) error {
+	return d.revise(messageID, RevisionDelete,
+		"UPDATE messages SET content = '', deleted_at = CURRENT_TIMESTAMP WHERE id = ?", messageID)
+}
+
+// revise saves the current content of a live message as a revision and
+// then runs update, all in one transaction. It returns sql.ErrNoRows if
+// the message doesn't exist or was deleted, and ErrRoomArchived if its
+// room is read-only.
+func (d *Database) revise(messageID int, action, update string, args ...interface{}) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var archived bool
+	err = tx.QueryRow(d.rebind(`
+		SELECT r.archived_at IS NOT NULL
+		FROM messages m JOIN chat_rooms r ON r.id = m.room_id
+		WHERE m.id = ?`), messageID).Scan(&archived)
+	if err != nil {
+		return err
+	}
+	if archived {
+		return ErrRoomArchived
+	}
+
+	result, err := tx.Exec(d.rebind(`
+		INSERT INTO message_revisions (message_id, action, content)
+		SELECT id, ?, content FROM messages WHERE id = ? AND deleted_at IS NULL`), action, messageID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(d.rebind(update), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetRevisions returns the earlier versions of a message, oldest first.
+func (d *Database) GetRevisions(messageID int) ([]Revision, error) {
+	rows, err := d.query(`
+		SELECT id, message_id, action, content, created_at
+		FROM message_revisions
+		WHERE message_id = ?
+		ORDER BY id`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		var r Revision
+		if err := rows.Scan(&r.ID, &r.MessageID, &r.Action, &r.Content, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
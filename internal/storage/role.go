@@ -0,0 +1,28 @@
+package storage
+
+// Roles a user can have, from least to most trusted
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleSysop     = "sysop"
+)
+
+// Roles lists every role from least to most trusted.
+var Roles = []string{RoleUser, RoleModerator, RoleSysop}
+
+// RoleRank orders roles by trust, 0 being the least; unknown roles rank
+// below everything.
+func RoleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetUserRole changes a user's role. It returns sql.ErrNoRows if there is
+// no such user.
+func (d *Database) SetUserRole(userID int, role string) error {
+	return requireRow(d.exec("UPDATE users SET role = ? WHERE id = ?", role, userID))
+}
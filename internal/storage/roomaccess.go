@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Who may enter a ChatRoom
+const (
+	RoomPublic   = "public"   // anyone
+	RoomPrivate  = "private"  // members only
+	RoomPassword = "password" // members, and anyone who knows the password
+)
+
+// RoomAccesses lists every kind of room access.
+var RoomAccesses = []string{RoomPublic, RoomPrivate, RoomPassword}
+
+// Matches rooms r the user can enter without a password; takes the user
+// ID twice
+const roomEnterable = `(r.access = 'public' OR r.owner_id = ?
+	OR EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = r.id AND rm.user_id = ?))`
+
+// RoomMember is someone let into a private or password room.
+type RoomMember struct {
+	UserID    int
+	Username  string
+	InvitedBy string // "" if they joined with the password
+	CreatedAt time.Time
+}
+
+// SetRoomAccess changes who may enter a room. password is only used, and
+// then required, for RoomPassword. It returns sql.ErrNoRows if there is no
+// such room.
+func (d *Database) SetRoomAccess(roomID int, access, password string) error {
+	var hash interface{}
+	if access == RoomPassword {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		hash = string(hashed)
+	}
+	result, err := d.exec("UPDATE chat_rooms SET access = ?, password = ? WHERE id = ?", access, hash, roomID)
+	return requireRow(result, err)
+}
+
+// SetRoomOwner hands a room to a user, or to nobody with 0. It returns
+// sql.ErrNoRows if there is no such room.
+func (d *Database) SetRoomOwner(roomID, userID int) error {
+	result, err := d.exec("UPDATE chat_rooms SET owner_id = ? WHERE id = ?", nullID(userID), roomID)
+	return requireRow(result, err)
+}
+
+// CheckRoomPassword reports whether password opens a password room.
+func (d *Database) CheckRoomPassword(roomID int, password string) (bool, error) {
+	var hash sql.NullString
+	if err := d.queryRow("SELECT password FROM chat_rooms WHERE id = ?", roomID).Scan(&hash); err != nil {
+		return false, err
+	}
+	if !hash.Valid {
+		return false, nil
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash.String), []byte(password)) == nil, nil
+}
+
+// AddRoomMember lets a user into a room. invitedBy is 0 when they got in
+// with the password. Adding an existing member does nothing.
+func (d *Database) AddRoomMember(roomID, userID, invitedBy int) error {
+	_, err := d.exec(`
+		INSERT INTO room_members (room_id, user_id, invited_by) VALUES (?, ?, ?)
+		ON CONFLICT (room_id, user_id) DO NOTHING`, roomID, userID, nullID(invitedBy))
+	return err
+}
+
+// RemoveRoomMember takes a user off a room's member list. It returns
+// sql.ErrNoRows if they weren't on it.
+func (d *Database) RemoveRoomMember(roomID, userID int) error {
+	result, err := d.exec("DELETE FROM room_members WHERE room_id = ? AND user_id = ?", roomID, userID)
+	return requireRow(result, err)
+}
+
+// IsRoomMember reports whether a user is on a room's member list.
+func (d *Database) IsRoomMember(roomID, userID int) (bool, error) {
+	var exists bool
+	err := d.queryRow(`
+		SELECT EXISTS (SELECT 1 FROM room_members WHERE room_id = ? AND user_id = ?)`,
+		roomID, userID).Scan(&exists)
+	return exists, err
+}
+
+// GetRoomMembers lists a room's members by name.
+func (d *Database) GetRoomMembers(roomID int) ([]RoomMember, error) {
+	rows, err := d.query(`
+		SELECT m.user_id, u.username, i.username, m.created_at
+		FROM room_members m
+		JOIN users u ON u.id = m.user_id
+		LEFT JOIN users i ON i.id = m.invited_by
+		WHERE m.room_id = ?
+		ORDER BY u.username`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []RoomMember
+	for rows.Next() {
+		var m RoomMember
+		var invitedBy sql.NullString
+		if err := rows.Scan(&m.UserID, &m.Username, &invitedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.InvitedBy = invitedBy.String
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// requireRow turns an update that matched nothing into sql.ErrNoRows.
+func requireRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
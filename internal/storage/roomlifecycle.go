@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrRoomExists is returned by CreateOwnedRoom when the name is taken.
+var ErrRoomExists = errors.New("room already exists")
+
+// ErrRoomArchived is returned by AddMessage, EditMessage and DeleteMessage
+// for a read-only room.
+var ErrRoomArchived = errors.New("room is archived")
+
+// Longest room name ValidRoomName accepts
+const MaxRoomNameLength = 30
+
+// ValidRoomName reports whether name can be given to a new room: 2 to
+// MaxRoomNameLength letters, digits, '-' or '_', so it's one word.
+func ValidRoomName(name string) bool {
+	if len(name) < 2 || len(name) > MaxRoomNameLength {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// FindChatRoom finds a room by name, ignoring case.
+func (d *Database) FindChatRoom(name string) (*ChatRoom, error) {
+	room, err := scanRoom(d.queryRow(roomColumns+" WHERE LOWER(r.name) = LOWER(?) ORDER BY r.id LIMIT 1", name))
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// CreateOwnedRoom creates a public room owned by a user.
+func (d *Database) CreateOwnedRoom(name, description string, ownerID int) error {
+	result, err := d.exec(`
+		INSERT INTO chat_rooms (name, description, owner_id) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO NOTHING`, name, description, ownerID)
+	if err := requireRow(result, err); err == sql.ErrNoRows {
+		return ErrRoomExists
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetRoomDescription changes the line shown next to a room in the room
+// list. It returns sql.ErrNoRows if there is no such room.
+func (d *Database) SetRoomDescription(roomID int, description string) error {
+	result, err := d.exec("UPDATE chat_rooms SET description = ? WHERE id = ?", description, roomID)
+	return requireRow(result, err)
+}
+
+// ArchiveRoom makes a room read-only, or writable again with false. It
+// returns sql.ErrNoRows if there is no such room.
+func (d *Database) ArchiveRoom(roomID int, archived bool) error {
+	archivedAt := "NULL"
+	if archived {
+		archivedAt = "CURRENT_TIMESTAMP"
+	}
+	result, err := d.exec("UPDATE chat_rooms SET archived_at = "+archivedAt+" WHERE id = ?", roomID)
+	return requireRow(result, err)
+}
+
+// DeleteChatRoom removes a room with its messages, their revisions, and
+// everything else that refers to it. It returns sql.ErrNoRows if there is
+// no such room.
+func (d *Database) DeleteChatRoom(roomID int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM pinned_messages WHERE room_id = ?",
+		"DELETE FROM room_topics WHERE room_id = ?",
+		"DELETE FROM message_revisions WHERE message_id IN (SELECT id FROM messages WHERE room_id = ?)",
+		"DELETE FROM messages WHERE room_id = ?",
+		"DELETE FROM room_reads WHERE room_id = ?",
+		"DELETE FROM room_members WHERE room_id = ?",
+		"DELETE FROM sanctions WHERE room_id = ?",
+	} {
+		if _, err := tx.Exec(d.rebind(query), roomID); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec(d.rebind("DELETE FROM chat_rooms WHERE id = ?"), roomID)
+	if err := requireRow(result, err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetIdleRooms returns the owned, unarchived rooms nobody has written in
+// since before, oldest first. Rooms without owners are never idle.
+func (d *Database) GetIdleRooms(before time.Time) ([]ChatRoom, error) {
+	rows, err := d.query(roomColumns+`
+		WHERE r.owner_id IS NOT NULL AND r.archived_at IS NULL AND r.created_at < ?
+			AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.room_id = r.id AND m.timestamp >= ?)
+		ORDER BY r.id`, d.timeArg(before), d.timeArg(before))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []ChatRoom
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
@@ -0,0 +1,86 @@
+package storage
+
+// GetLastRead returns the ID of the newest message the user has seen in a
+// room, or sql.ErrNoRows if they have never been there.
+func (d *Database) GetLastRead(userID, roomID int) (int, error) {
+	var messageID int
+	err := d.queryRow("SELECT last_message_id FROM room_reads WHERE user_id = ? AND room_id = ?", userID, roomID).
+		Scan(&messageID)
+	return messageID, err
+}
+
+// MarkRoomRead moves the user's read pointer in a room up to its newest
+// message. It never moves backwards.
+func (d *Database) MarkRoomRead(userID, roomID int) error {
+	_, err := d.exec(`
+		INSERT INTO room_reads (user_id, room_id, last_message_id)
+		SELECT ?, ?, COALESCE(MAX(id), 0) FROM messages WHERE room_id = ?
+		ON CONFLICT (user_id, room_id) DO UPDATE SET last_message_id = excluded.last_message_id
+		WHERE excluded.last_message_id > room_reads.last_message_id`, userID, roomID, roomID)
+	return err
+}
+
+// GetMessagesSince returns the newest limit messages in a room after
+// afterID, oldest first.
+func (d *Database) GetMessagesSince(roomID, afterID, limit int) ([]Message, error) {
+	rows, err := d.query(`
+		SELECT `+messageColumns+`
+		FROM messages m
+		WHERE m.room_id = ? AND m.id > ? AND m.deleted_at IS NULL
+		ORDER BY m.id DESC
+		LIMIT ?`, roomID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// CountMessagesSince counts the messages in a room after afterID.
+func (d *Database) CountMessagesSince(roomID, afterID int) (int, error) {
+	var count int
+	err := d.queryRow("SELECT COUNT(*) FROM messages WHERE room_id = ? AND id > ? AND deleted_at IS NULL", roomID, afterID).Scan(&count)
+	return count, err
+}
+
+// GetUnreadCounts maps room IDs to the number of messages the user hasn't
+// seen there. Rooms with nothing unread are left out; rooms the user has
+// never visited count every message.
+func (d *Database) GetUnreadCounts(userID int) (map[int]int, error) {
+	rows, err := d.query(`
+		SELECT m.room_id, COUNT(*)
+		FROM messages m
+		LEFT JOIN room_reads r ON r.room_id = m.room_id AND r.user_id = ?
+		WHERE m.id > COALESCE(r.last_message_id, 0) AND m.deleted_at IS NULL
+		GROUP BY m.room_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var roomID, count int
+		if err := rows.Scan(&roomID, &count); err != nil {
+			return nil, err
+		}
+		counts[roomID] = count
+	}
+	return counts, rows.Err()
+}
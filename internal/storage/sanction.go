@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of Sanction
+const (
+	SanctionKick = "kick" // recorded for the log; never active
+	SanctionMute = "mute"
+	SanctionBan  = "ban"
+)
+
+// Sanction is a moderation action against a user or an IP address.
+type Sanction struct {
+	ID         int
+	Kind       string
+	UserID     int    // 0 for an IP-only ban
+	Username   string // of UserID
+	IP         string // "" unless an IP ban
+	RoomID     int    // mutes only; 0 means every room
+	RoomName   string
+	Reason     string
+	IssuedBy   int
+	IssuerName string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time // zero for permanent
+	LiftedAt   time.Time // zero unless lifted early
+}
+
+const sanctionColumns = `
+	SELECT s.id, s.kind, s.user_id, u.username, s.ip, s.room_id, r.name, s.reason,
+		s.issued_by, i.username, s.created_at, s.expires_at, s.lifted_at
+	FROM sanctions s
+	LEFT JOIN users u ON u.id = s.user_id
+	LEFT JOIN chat_rooms r ON r.id = s.room_id
+	JOIN users i ON i.id = s.issued_by`
+
+// Matches sanctions in force now; takes the current time as its argument
+const sanctionActive = "s.lifted_at IS NULL AND (s.expires_at IS NULL OR s.expires_at > ?)"
+
+func scanSanction(scanner interface{ Scan(...interface{}) error }) (Sanction, error) {
+	var s Sanction
+	var userID, roomID sql.NullInt64
+	var username, ip, roomName sql.NullString
+	var expiresAt, liftedAt sql.NullTime
+	err := scanner.Scan(&s.ID, &s.Kind, &userID, &username, &ip, &roomID, &roomName, &s.Reason,
+		&s.IssuedBy, &s.IssuerName, &s.CreatedAt, &expiresAt, &liftedAt)
+	s.UserID, s.Username = int(userID.Int64), username.String
+	s.IP = ip.String
+	s.RoomID, s.RoomName = int(roomID.Int64), roomName.String
+	s.ExpiresAt, s.LiftedAt = expiresAt.Time, liftedAt.Time
+	return s, err
+}
+
+func (d *Database) querySanctions(query string, args ...interface{}) ([]Sanction, error) {
+	rows, err := d.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sanctions []Sanction
+	for rows.Next() {
+		s, err := scanSanction(rows)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, s)
+	}
+	return sanctions, rows.Err()
+}
+
+// AddSanction records s and returns its ID. ID, the names and the
+// timestamps other than ExpiresAt are ignored.
+func (d *Database) AddSanction(s Sanction) (int, error) {
+	var expiresAt interface{}
+	if !s.ExpiresAt.IsZero() {
+		expiresAt = d.timeArg(s.ExpiresAt)
+	}
+
+	var id int
+	err := d.queryRow(`
+		INSERT INTO sanctions (kind, user_id, ip, room_id, reason, issued_by, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		s.Kind, nullID(s.UserID), nullString(s.IP), nullID(s.RoomID), s.Reason, s.IssuedBy, expiresAt).Scan(&id)
+	return id, err
+}
+
+// GetActiveSanctions returns the mutes and bans in force, oldest first.
+func (d *Database) GetActiveSanctions() ([]Sanction, error) {
+	return d.querySanctions(sanctionColumns+" WHERE s.kind <> ? AND "+sanctionActive+" ORDER BY s.id",
+		SanctionKick, d.timeArg(time.Now()))
+}
+
+// GetUserSanctions returns every sanction ever issued against a user,
+// newest first.
+func (d *Database) GetUserSanctions(userID int) ([]Sanction, error) {
+	return d.querySanctions(sanctionColumns+" WHERE s.user_id = ? ORDER BY s.id DESC", userID)
+}
+
+// GetActiveBan returns a ban in force against the user or the IP address,
+// either of which may be left out with 0 or "". It returns sql.ErrNoRows
+// if there is none.
+func (d *Database) GetActiveBan(userID int, ip string) (*Sanction, error) {
+	s, err := scanSanction(d.queryRow(sanctionColumns+`
+		WHERE s.kind = ? AND `+sanctionActive+` AND (s.user_id = ? OR s.ip = ?)
+		ORDER BY s.id DESC
+		LIMIT 1`, SanctionBan, d.timeArg(time.Now()), nullID(userID), nullString(ip)))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetActiveMute returns a mute in force against the user in the room or
+// everywhere. It returns sql.ErrNoRows if there is none.
+func (d *Database) GetActiveMute(userID, roomID int) (*Sanction, error) {
+	s, err := scanSanction(d.queryRow(sanctionColumns+`
+		WHERE s.kind = ? AND `+sanctionActive+` AND s.user_id = ? AND (s.room_id IS NULL OR s.room_id = ?)
+		ORDER BY s.id DESC
+		LIMIT 1`, SanctionMute, d.timeArg(time.Now()), userID, roomID))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LiftSanctions ends every active sanction of kind against the user or
+// the IP address early and reports how many there were. liftedBy is 0
+// when lifted from the admin tool.
+func (d *Database) LiftSanctions(kind string, userID int, ip string, liftedBy int) (int, error) {
+	result, err := d.exec(`
+		UPDATE sanctions SET lifted_at = CURRENT_TIMESTAMP, lifted_by = ?
+		WHERE kind = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+			AND (user_id = ? OR ip = ?)`,
+		nullID(liftedBy), kind, d.timeArg(time.Now()), nullID(userID), nullString(ip))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// nullID stores 0 as NULL, for optional references.
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
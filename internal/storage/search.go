@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How SearchMessages finds matches, as reported by SearchMode
+const (
+	SearchFTS5     = "fts5"     // SQLite FTS5 index kept in sync by triggers
+	SearchTSVector = "tsvector" // PostgreSQL generated tsvector column
+	SearchLike     = "like"     // no index: SQLite built without FTS5
+)
+
+// The FTS5 index and the triggers that keep it in step with messages. They
+// live outside the migrations because FTS5 is only compiled into SQLite
+// with the sqlite_fts5 build tag.
+const ftsSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(content, content='messages', content_rowid='id');
+CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+	INSERT INTO messages_fts (rowid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+	INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+	INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	INSERT INTO messages_fts (rowid, content) VALUES (new.id, new.content);
+END;
+`
+
+// Dropping the triggers lets a build without FTS5 keep writing messages to
+// a database an FTS5 build has indexed. The next FTS5 build rebuilds it.
+const ftsDropTriggers = `
+DROP TRIGGER IF EXISTS messages_fts_insert;
+DROP TRIGGER IF EXISTS messages_fts_delete;
+DROP TRIGGER IF EXISTS messages_fts_update;
+`
+
+// SearchQuery selects messages for SearchMessages.
+type SearchQuery struct {
+	Terms  []string // words or phrases that must all appear
+	RoomID int      // only this room, or 0 for all
+	UserID int      // only this author, or 0 for anyone
+
+	// ViewerID leaves out private and password rooms this user can't
+	// enter; 0 searches every room
+	ViewerID int
+
+	// Highlight goes before and after each match in a result's Snippet
+	Highlight [2]string
+}
+
+// SearchResult is a matching message and the room it was said in.
+type SearchResult struct {
+	Message
+	RoomName string
+
+	// Snippet is the part of the content around the matches, with the
+	// query's Highlight around each. FTS5 searches only; "" otherwise.
+	Snippet string
+}
+
+// Most tokens of content an FTS5 snippet shows
+const snippetTokens = 12
+
+// SearchMode reports how messages are searched.
+func (d *Database) SearchMode() string {
+	return d.search
+}
+
+// setupSearch picks the search mode for a migrated database, creating or
+// retiring the FTS5 index to match what this build of SQLite supports.
+func (d *Database) setupSearch() error {
+	if d.driver == DriverPostgres {
+		d.search = SearchTSVector
+		return nil
+	}
+
+	fts, err := d.hasFTS5()
+	if err != nil {
+		return err
+	}
+	if !fts {
+		d.search = SearchLike
+		_, err := d.exec(ftsDropTriggers)
+		return err
+	}
+
+	// Missing triggers mean the index is new or fell behind while a build
+	// without FTS5 ran
+	var triggers int
+	if err := d.queryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'messages_fts_%'").Scan(&triggers); err != nil {
+		return err
+	}
+	if triggers < 3 {
+		if err := d.rebuildFTS(); err != nil {
+			return err
+		}
+	}
+	d.search = SearchFTS5
+	return nil
+}
+
+// detectSearch works out the search mode of a database opened without
+// migrating, changing nothing.
+func (d *Database) detectSearch() error {
+	if d.driver == DriverPostgres {
+		d.search = SearchTSVector
+		return nil
+	}
+
+	d.search = SearchLike
+	fts, err := d.hasFTS5()
+	if err != nil || !fts {
+		return err
+	}
+	var triggers int
+	if err := d.queryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'messages_fts_%'").Scan(&triggers); err != nil {
+		return err
+	}
+	if triggers == 3 {
+		d.search = SearchFTS5
+	}
+	return nil
+}
+
+func (d *Database) hasFTS5() (bool, error) {
+	var n int
+	err := d.queryRow("SELECT COUNT(*) FROM pragma_compile_options WHERE compile_options = 'ENABLE_FTS5'").Scan(&n)
+	return n > 0, err
+}
+
+// rebuildFTS creates the FTS5 index if needed and refills it from messages.
+func (d *Database) rebuildFTS() error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ftsSchema); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO messages_fts (messages_fts) VALUES ('rebuild')"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RebuildSearchIndex regenerates the full-text index from the messages
+// table. It fails if this build has no full-text index to rebuild.
+func (d *Database) RebuildSearchIndex() error {
+	switch d.driver {
+	case DriverPostgres:
+		_, err := d.exec("REINDEX INDEX messages_search")
+		return err
+	default:
+		fts, err := d.hasFTS5()
+		if err != nil {
+			return err
+		}
+		if !fts {
+			return fmt.Errorf("this build's SQLite has no FTS5; build with -tags sqlite_fts5")
+		}
+		if err := d.rebuildFTS(); err != nil {
+			return err
+		}
+		d.search = SearchFTS5
+		return nil
+	}
+}
+
+// SearchMessages returns up to limit messages matching q, newest first.
+func (d *Database) SearchMessages(q SearchQuery, limit int) ([]SearchResult, error) {
+	if len(q.Terms) == 0 {
+		return nil, nil
+	}
+
+	from := "messages m"
+	snippet := "''"
+	var where []string
+	var args []interface{}
+
+	switch d.search {
+	case SearchFTS5:
+		from = "messages_fts f JOIN messages m ON m.id = f.rowid"
+		snippet = "snippet(messages_fts, 0, ?, ?, '...', ?)"
+		args = append(args, q.Highlight[0], q.Highlight[1], snippetTokens)
+		phrases := make([]string, len(q.Terms))
+		for i, term := range q.Terms {
+			phrases[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+		where = append(where, "messages_fts MATCH ?")
+		args = append(args, strings.Join(phrases, " "))
+	case SearchTSVector:
+		for _, term := range q.Terms {
+			where = append(where, "m.search @@ phraseto_tsquery('simple', ?)")
+			args = append(args, term)
+		}
+	default:
+		for _, term := range q.Terms {
+			where = append(where, `m.content LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+likeEscaper.Replace(term)+"%")
+		}
+	}
+	where = append(where, "m.deleted_at IS NULL")
+	if q.RoomID > 0 {
+		where = append(where, "m.room_id = ?")
+		args = append(args, q.RoomID)
+	}
+	if q.UserID > 0 {
+		where = append(where, "m.user_id = ?")
+		args = append(args, q.UserID)
+	}
+	if q.ViewerID > 0 {
+		where = append(where, roomEnterable)
+		args = append(args, q.ViewerID, q.ViewerID)
+	}
+	args = append(args, limit)
+
+	rows, err := d.query(`
+		SELECT `+messageColumns+`, r.name, `+snippet+`
+		FROM `+from+`
+		JOIN chat_rooms r ON r.id = m.room_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY m.id DESC
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		r.Message, err = scanMessage(rows, &r.RoomName, &r.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,85 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// These only run in builds with FTS5 compiled in, as CI runs them with
+// "go test -tags sqlite_fts5 ./..."; the default build searches with LIKE.
+
+func TestFTS5Index(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bbs.db")
+	db, err := NewDatabase(DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { db.Close() }()
+	if mode := db.SearchMode(); mode != SearchFTS5 {
+		t.Fatalf("SearchMode = %q in a sqlite_fts5 build, want %q", mode, SearchFTS5)
+	}
+
+	db.CreateUser("alice", "secret")
+	alice, _ := db.GetUserByName("alice")
+	db.CreateChatRoom("General", "")
+	general, _ := db.GetChatRoom("General")
+	db.AddMessage(general.ID, alice.ID, alice.Username, "the quick brown fox jumps over the lazy dog")
+
+	search := func(term string) []SearchResult {
+		t.Helper()
+		results, err := db.SearchMessages(SearchQuery{Terms: []string{term}, Highlight: [2]string{"[", "]"}}, 10)
+		if err != nil {
+			t.Fatalf("SearchMessages(%q): %v", term, err)
+		}
+		return results
+	}
+
+	results := search("fox")
+	if len(results) != 1 {
+		t.Fatalf("search fox = %+v", results)
+	}
+	if want := "the quick brown [fox] jumps over the lazy dog"; results[0].Snippet != want {
+		t.Errorf("Snippet = %q, want %q", results[0].Snippet, want)
+	}
+
+	// The triggers follow edits and deletions
+	msg, _ := db.GetLastMessage(general.ID, alice.ID)
+	if err := db.EditMessage(msg.ID, "a slow red hen"); err != nil {
+		t.Fatal(err)
+	}
+	if results := search("fox"); len(results) != 0 {
+		t.Errorf("edited-out word still found: %+v", results)
+	}
+	if results := search("hen"); len(results) != 1 {
+		t.Errorf("edited-in word not found: %+v", results)
+	}
+	if err := db.DeleteMessage(msg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if results := search("hen"); len(results) != 0 {
+		t.Errorf("deleted message found: %+v", results)
+	}
+
+	// A build without FTS5 drops the triggers and writes behind the
+	// index's back; the next FTS5 build catches the index up
+	if _, err := db.exec(ftsDropTriggers); err != nil {
+		t.Fatal(err)
+	}
+	db.AddMessage(general.ID, alice.ID, alice.Username, "written while unindexed")
+	db.Close()
+	if db, err = NewDatabase(DriverSQLite, path); err != nil {
+		t.Fatal(err)
+	}
+	if results := search("unindexed"); len(results) != 1 {
+		t.Errorf("message written without the triggers not found after reopening: %+v", results)
+	}
+
+	if err := db.RebuildSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if results := search("unindexed"); len(results) != 1 {
+		t.Errorf("after RebuildSearchIndex: %+v", results)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import "time"
+
+// UserStore manages accounts, their roles and their SSH login keys.
+type UserStore interface {
+	CreateUser(username, password string) error
+	AuthenticateUser(username, password string) (*User, error)
+	GetUserByName(username string) (*User, error)
+	ListUsers() ([]User, error)
+	AddUserKey(userID int, fingerprint, publicKey, comment string) error
+	GetUserKeys(userID int) ([]UserKey, error)
+	DeleteUserKey(userID, keyID int) error
+	GetUserByKey(fingerprint string) (*User, error)
+	UpdateLastSeen(userID int) error
+	SetUserRole(userID int, role string) error
+}
+
+// RoomStore manages chat rooms, who owns them and who may enter them.
+type RoomStore interface {
+	GetChatRooms() ([]ChatRoom, error)
+	CreateChatRoom(name, description string) error
+	GetChatRoom(name string) (*ChatRoom, error)
+	FindChatRoom(name string) (*ChatRoom, error)
+	SetRoomAccess(roomID int, access, password string) error
+	SetRoomOwner(roomID, userID int) error
+	CheckRoomPassword(roomID int, password string) (bool, error)
+	AddRoomMember(roomID, userID, invitedBy int) error
+	RemoveRoomMember(roomID, userID int) error
+	IsRoomMember(roomID, userID int) (bool, error)
+	GetRoomMembers(roomID int) ([]RoomMember, error)
+	CreateOwnedRoom(name, description string, ownerID int) error
+	SetRoomDescription(roomID int, description string) error
+	ArchiveRoom(roomID int, archived bool) error
+	DeleteChatRoom(roomID int) error
+	GetIdleRooms(before time.Time) ([]ChatRoom, error)
+	SetRoomTopic(roomID int, topic string, userID int) error
+	GetTopicHistory(roomID, limit int) ([]TopicChange, error)
+}
+
+// MessageStore keeps chat room history. Deleted messages keep their ID
+// but are left out of everything except GetRevisions.
+type MessageStore interface {
+	AddMessage(roomID, userID int, username, content string) error
+	GetRecentMessages(roomID int, limit int) ([]Message, error)
+	GetHistory(q HistoryQuery, limit int) ([]Message, error)
+	GetMessage(messageID int) (*Message, error)
+	GetMessageRoom(messageID int) (*ChatRoom, error)
+	GetLastMessage(roomID, userID int) (*Message, error)
+	EditMessage(messageID int, content string) error
+	DeleteMessage(messageID int) error
+	GetRevisions(messageID int) ([]Revision, error)
+}
+
+// PinStore keeps the messages pinned to the top of each room.
+type PinStore interface {
+	PinMessage(messageID, userID int) error
+	UnpinMessage(messageID int) error
+	GetPinnedMessages(roomID int) ([]PinnedMessage, error)
+}
+
+// RoomReadStore remembers how far each user has read in each chat room.
+type RoomReadStore interface {
+	GetLastRead(userID, roomID int) (int, error)
+	MarkRoomRead(userID, roomID int) error
+	GetMessagesSince(roomID, afterID, limit int) ([]Message, error)
+	CountMessagesSince(roomID, afterID int) (int, error)
+	GetUnreadCounts(userID int) (map[int]int, error)
+}
+
+// SearchStore finds chat messages by content.
+type SearchStore interface {
+	SearchMessages(q SearchQuery, limit int) ([]SearchResult, error)
+	SearchMode() string
+	RebuildSearchIndex() error
+}
+
+// SanctionStore keeps the moderation log: kicks, mutes and bans.
+type SanctionStore interface {
+	AddSanction(s Sanction) (int, error)
+	GetActiveSanctions() ([]Sanction, error)
+	GetUserSanctions(userID int) ([]Sanction, error)
+	GetActiveBan(userID int, ip string) (*Sanction, error)
+	GetActiveMute(userID, roomID int) (*Sanction, error)
+	LiftSanctions(kind string, userID int, ip string, liftedBy int) (int, error)
+}
+
+// DirectMessageStore keeps private conversations between two users.
+type DirectMessageStore interface {
+	AddDirectMessage(fromID, toID int, content string) error
+	GetDirectMessages(userID, otherID, limit, offset int) ([]DirectMessage, error)
+	CountDirectMessages(userID, otherID int) (int, error)
+	GetCorrespondents(userID int) ([]Correspondent, error)
+}
+
+// MailStore keeps each member's inbox. Every method taking a userID only
+// touches mail addressed to that user.
+type MailStore interface {
+	SendMail(fromID, toID int, subject, body string) error
+	GetInbox(userID int) ([]Mail, error)
+	GetMail(userID, mailID int) (*Mail, error)
+	MarkMailRead(userID, mailID int) error
+	DeleteMail(userID, mailID int) error
+	CountUnreadMail(userID int) (int, error)
+}
+
+// BoardStore keeps the bulletin boards and each user's read position in
+// every thread.
+type BoardStore interface {
+	CreateBoard(name, description string) error
+	GetBoards(userID int) ([]Board, error)
+	GetBoard(name string) (*Board, error)
+	GetThreads(boardID, userID, limit, offset int) ([]Thread, error)
+	GetThread(threadID, userID int) (*Thread, error)
+	CreateThread(boardID, userID int, subject, body string) (int, error)
+	AddPost(threadID, userID int, body string) (int, error)
+	GetPosts(threadID, limit, offset int) ([]Post, error)
+	MarkThreadRead(userID, threadID, postID int) error
+	GetUnreadPosts(userID, limit int) ([]Post, error)
+	CountUnreadPosts(userID int) (int, error)
+}
+
+// MOTDStore keeps the message of the day; the newest one wins.
+type MOTDStore interface {
+	GetMOTD() (*MOTD, error)
+	SetMOTD(content, updatedBy string) error
+}
+
+// Store is everything the BBS keeps between restarts. Lookups that find
+// nothing return sql.ErrNoRows whatever the backend.
+type Store interface {
+	UserStore
+	RoomStore
+	MessageStore
+	RoomReadStore
+	SearchStore
+	SanctionStore
+	PinStore
+	DirectMessageStore
+	MailStore
+	BoardStore
+	MOTDStore
+
+	// Seed creates the given rooms and boards if missing and sets the
+	// MOTD if there is none yet.
+	Seed(rooms []ChatRoom, boards []Board, motd string) error
+
+	Version() (int, error)
+	Migrations() ([]Migration, error)
+	PendingMigrations() ([]Migration, error)
+	Migrate() ([]Migration, error)
+
+	Close() error
+}
+
+var _ Store = (*Database)(nil)
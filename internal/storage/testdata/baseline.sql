@@ -0,0 +1,49 @@
+-- A database as written by the original release: no schema_version, no
+-- user_keys.
+
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_seen DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE chat_rooms (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	description TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	room_id INTEGER,
+	user_id INTEGER,
+	username TEXT,
+	content TEXT NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (room_id) REFERENCES chat_rooms(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE motd (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	content TEXT NOT NULL,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_by TEXT
+);
+
+INSERT INTO users (username, password, joined_at, last_seen) VALUES
+	('alice', '$2a$10$invalidhashinvalidhashinvalidhashinvalidhashinvalidha', '2024-01-02 10:00:00', '2024-01-03 11:00:00'),
+	('bob', '$2a$10$invalidhashinvalidhashinvalidhashinvalidhashinvalidha', '2024-01-02 12:00:00', '2024-01-02 12:30:00');
+
+INSERT INTO chat_rooms (name, description) VALUES
+	('General', 'General discussion for all users'),
+	('Tech', 'Technology and programming discussions');
+
+INSERT INTO messages (room_id, user_id, username, content, timestamp) VALUES
+	(1, 1, 'alice', 'hello', '2024-01-02 10:05:00'),
+	(1, 2, 'bob', 'hi alice', '2024-01-02 12:05:00');
+
+INSERT INTO motd (content, updated_by) VALUES ('Welcome!', 'System');
@@ -0,0 +1,57 @@
+package storage
+
+import "time"
+
+// TopicChange is one entry in a room's topic history.
+type TopicChange struct {
+	Topic string // "" when the topic was cleared
+	SetBy string
+	SetAt time.Time
+}
+
+// SetRoomTopic changes a room's topic and records who changed it. It
+// returns sql.ErrNoRows if there is no such room.
+func (d *Database) SetRoomTopic(roomID int, topic string, userID int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(d.rebind(`
+		UPDATE chat_rooms SET topic = ?, topic_by = ?, topic_at = CURRENT_TIMESTAMP
+		WHERE id = ?`), topic, userID, roomID)
+	if err := requireRow(result, err); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.rebind("INSERT INTO room_topics (room_id, topic, set_by) VALUES (?, ?, ?)"),
+		roomID, topic, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTopicHistory returns a room's latest topic changes, newest first.
+func (d *Database) GetTopicHistory(roomID, limit int) ([]TopicChange, error) {
+	rows, err := d.query(`
+		SELECT t.topic, u.username, t.created_at
+		FROM room_topics t
+		JOIN users u ON u.id = t.set_by
+		WHERE t.room_id = ?
+		ORDER BY t.id DESC
+		LIMIT ?`, roomID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []TopicChange
+	for rows.Next() {
+		var c TopicChange
+		if err := rows.Scan(&c.Topic, &c.SetBy, &c.SetAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
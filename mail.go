@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// handleMail runs the "mail" command: inbox, send, read, reply and delete.
+func (c *Client) handleMail(args []string) {
+	if len(args) == 0 || strings.ToLower(args[0]) == "inbox" {
+		c.showInbox()
+		return
+	}
+
+	subcommand := strings.ToLower(args[0])
+	if subcommand == "send" {
+		if len(args) < 2 {
+			c.write("Usage: /mail send <user>\n")
+			return
+		}
+		c.composeMail(args[1], "")
+		return
+	}
+
+	if len(args) < 2 {
+		c.write("Usage: /mail [inbox|send <user>|read <id>|reply <id>|delete <id>]\n")
+		return
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		c.write(fmt.Sprintf("Usage: /mail %s <id>\n", subcommand))
+		return
+	}
+
+	switch subcommand {
+	case "read":
+		c.readMail(id)
+	case "reply":
+		mail, err := c.db.GetMail(c.user.ID, id)
+		if err != nil {
+			c.write(fmt.Sprintf("No message %d in your inbox.\n", id))
+			return
+		}
+		subject := mail.Subject
+		if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+			subject = "Re: " + subject
+		}
+		c.composeMail(mail.FromName, subject)
+	case "delete", "del":
+		if err := c.db.DeleteMail(c.user.ID, id); err == sql.ErrNoRows {
+			c.write(fmt.Sprintf("No message %d in your inbox.\n", id))
+		} else if err != nil {
+			c.write("Failed to delete message.\n")
+		} else {
+			c.write(fmt.Sprintf("Message %d deleted.\n", id))
+		}
+	default:
+		c.write("Usage: /mail [inbox|send <user>|read <id>|reply <id>|delete <id>]\n")
+	}
+}
+
+func (c *Client) showInbox() {
+	inbox, err := c.db.GetInbox(c.user.ID)
+	if err != nil {
+		c.write("Error loading your inbox.\n")
+		return
+	}
+	if len(inbox) == 0 {
+		c.write("Your inbox is empty. Write to someone with '/mail send <user>'.\n")
+		return
+	}
+
+	unread := 0
+	for _, mail := range inbox {
+		if !mail.Read {
+			unread++
+		}
+	}
+
+	width, _ := c.windowSize()
+	c.write(fmt.Sprintf("\033[36mInbox (%d messages, %d unread):\033[0m\n", len(inbox), unread))
+	c.write(c.separator("-", 70) + "\n")
+	for _, mail := range inbox {
+		marker := " "
+		if !mail.Read {
+			marker = "\033[32m*\033[0m"
+		}
+		line := fmt.Sprintf("%s %4d  %-16s %s  ", marker, mail.ID, truncateText(mail.FromName, 16), mail.SentAt.Format("2006-01-02 15:04"))
+		c.write(line + truncateText(mail.Subject, width-visibleLen(line)) + "\n")
+	}
+	c.write(c.separator("-", 70) + "\n")
+	c.write("* = unread. Use '/mail read <id>' to open a message.\n\n")
+}
+
+func (c *Client) readMail(id int) {
+	mail, err := c.db.GetMail(c.user.ID, id)
+	if err != nil {
+		c.write(fmt.Sprintf("No message %d in your inbox.\n", id))
+		return
+	}
+	c.db.MarkMailRead(c.user.ID, id)
+
+	width, _ := c.windowSize()
+	c.write(c.separator("=", 60) + "\n")
+	c.write(fmt.Sprintf("\033[33mFrom:\033[0m    %s\n", mail.FromName))
+	c.write(fmt.Sprintf("\033[33mDate:\033[0m    %s\n", mail.SentAt.Format("2006-01-02 15:04")))
+	c.write(fmt.Sprintf("\033[33mSubject:\033[0m %s\n", mail.Subject))
+	c.write(c.separator("-", 60) + "\n")
+	c.write(wrapText(mail.Body, width, 0) + "\n")
+	c.write(c.separator("=", 60) + "\n")
+	c.write(fmt.Sprintf("Reply with '/mail reply %d' or remove it with '/mail delete %d'.\n\n", mail.ID, mail.ID))
+}
+
+// composeMail prompts for a subject (unless given) and a body ended by a
+// line holding only ".", then delivers the message.
+func (c *Client) composeMail(username, subject string) {
+	recipient, err := c.db.GetUserByName(username)
+	if err != nil {
+		c.write(fmt.Sprintf("No such user '%s'.\n", username))
+		return
+	}
+
+	c.write(fmt.Sprintf("To: %s\n", recipient.Username))
+	if subject == "" {
+		c.write("Subject: ")
+		if !c.scanner.Scan() {
+			return
+		}
+		subject = strings.TrimSpace(c.scanner.Text())
+		if subject == "" {
+			subject = "(no subject)"
+		}
+	} else {
+		c.write(fmt.Sprintf("Subject: %s\n", subject))
+	}
+
+	body, ok := c.readText(maxTextLines)
+	if !ok {
+		return
+	}
+
+	if err := c.db.SendMail(c.user.ID, recipient.ID, subject, body); err != nil {
+		c.write("Failed to send message.\n")
+		return
+	}
+
+	c.server.SendToUser(recipient.ID, fmt.Sprintf("\033[35mNew mail from %s: %s\033[0m\n", c.user.Username, subject))
+	c.write(fmt.Sprintf("\033[32mMail sent to %s.\033[0m\n", recipient.Username))
+}
+
+// showMailNotice tells the user about unread mail at login.
+func (c *Client) showMailNotice() {
+	unread, err := c.db.CountUnreadMail(c.user.ID)
+	if err != nil || unread == 0 {
+		return
+	}
+
+	noun := "messages"
+	if unread == 1 {
+		noun = "message"
+	}
+	c.write(fmt.Sprintf("\033[33mYou have %d new %s. Type '/mail' to read them.\033[0m\n", unread, noun))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// sendMail composes a message from c through the interactive editor.
+func sendMail(c *testClient, to, subject string, body ...string) {
+	c.t.Helper()
+
+	c.send("/mail send " + to)
+	c.expect("Subject: ")
+	c.send(subject)
+	c.expect("End with a line containing only '.'")
+	for _, line := range body {
+		c.send(line)
+	}
+	c.send(".")
+	c.expect("Mail sent to " + to + ".")
+	c.expectPrompt()
+}
+
+func TestMailToOfflineUser(t *testing.T) {
+	s := startTestServer(t, nil)
+	bob := s.register("bob", "secret")
+	bob.send("quit")
+	bob.expect("Goodbye!")
+
+	alice := s.register("alice", "secret")
+	sendMail(alice, "bob", "Lunch", "Noon tomorrow?", "", "My treat.")
+	sendMail(alice, "bob", "Also", "Bring the book.")
+
+	bob = s.dial()
+	bob.expect("(L)ogin or (R)egister?")
+	bob.send("l")
+	bob.expect("Username:")
+	bob.send("bob")
+	bob.expect("Password:")
+	bob.send("secret")
+	bob.expect("MESSAGE OF THE DAY")
+	bob.expect("You have 2 new messages. Type '/mail' to read them.")
+	bob.expectPrompt()
+
+	bob.send("/mail")
+	out := bob.expectPrompt()
+	if !strings.Contains(out, "Inbox (2 messages, 2 unread)") || !strings.Contains(out, "alice") || !strings.Contains(out, "Lunch") {
+		t.Fatalf("inbox: %q", out)
+	}
+
+	bob.send("/mail read 1")
+	out = bob.expectPrompt()
+	if !strings.Contains(out, "Subject: Lunch") || !strings.Contains(out, "Noon tomorrow?\n\nMy treat.") {
+		t.Errorf("read: %q", out)
+	}
+
+	bob.send("/mail")
+	bob.expect("Inbox (2 messages, 1 unread)")
+	bob.expectPrompt()
+
+	bob.send("/mail delete 1")
+	bob.expect("Message 1 deleted.")
+	bob.send("/mail read 1")
+	bob.expect("No message 1 in your inbox.")
+}
+
+func TestMailReply(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	sendMail(alice, "bob", "Question", "Are you coming?")
+	bob.expect("New mail from alice: Question")
+
+	bob.send("/mail reply 1")
+	bob.expect("To: alice")
+	bob.expect("Subject: Re: Question")
+	bob.send("Yes!")
+	bob.send(".")
+	bob.expect("Mail sent to alice.")
+	alice.expect("New mail from bob: Re: Question")
+
+	alice.send("/mail read 2")
+	alice.expect("From:    bob")
+	alice.expect("Yes!")
+}
+
+func TestMailErrors(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	s.register("bob", "secret")
+
+	alice.send("/mail")
+	alice.expect("Your inbox is empty.")
+	alice.send("/mail send nobody")
+	alice.expect("No such user 'nobody'.")
+	alice.send("/mail read 99")
+	alice.expect("No message 99 in your inbox.")
+
+	alice.send("/mail send bob")
+	alice.expect("Subject: ")
+	alice.send("Nothing")
+	alice.expect("End with a line containing only '.'")
+	alice.send(".")
+	alice.expect("Message cancelled (empty).")
+}
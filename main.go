@@ -11,24 +11,50 @@ package main
 
 import (
 	"log"
+	"net"
+	"os"
+
+	"bbs/internal/storage"
 )
 
 func main() {
+	// Load configuration from file, environment and flags
+	config, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	log.Printf("Resolved configuration:\n%s", config)
+
 	// Initialize database
-	db, err := NewDatabase()
+	db, err := storage.NewDatabase(config.Database.Driver, config.Database.Source())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	if mode := db.SearchMode(); mode == storage.SearchLike {
+		log.Printf("Message search: %s (build with -tags sqlite_fts5 for a full-text index)", mode)
+	} else {
+		log.Printf("Message search: %s", mode)
+	}
+
+	// Create default chat rooms, boards and MOTD
+	rooms, boards := config.SeedData()
+	if err := db.Seed(rooms, boards, config.Seed.MOTD); err != nil {
+		log.Fatalf("Failed to create default data: %v", err)
+	}
 
 	// Create and start BBS server
-	server := NewBBSServer(db)
-	
+	server := NewBBSServer(db, config)
+
 	log.Println("Starting Enhanced BBS Server...")
 	log.Println("Features: Chat Rooms, User Auth, Message History, MOTD")
-	log.Println("Connect via telnet: telnet localhost 3003")
-	
-	if err := server.Start("3003"); err != nil {
+	if config.Listen.Telnet != "" {
+		if _, port, err := net.SplitHostPort(config.Listen.Telnet); err == nil {
+			log.Printf("Connect via telnet: telnet localhost %s", port)
+		}
+	}
+
+	if err := server.Start(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
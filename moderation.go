@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+// remoteIP returns the address the client is connecting from, without
+// the port.
+func (c *Client) remoteIP() string {
+	return addrIP(c.conn.RemoteAddr())
+}
+
+func addrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// addrBan returns the ban in force against a connecting address, if any.
+func (s *BBSServer) addrBan(addr net.Addr) *storage.Sanction {
+	return s.ipBan(addrIP(addr))
+}
+
+func (s *BBSServer) ipBan(ip string) *storage.Sanction {
+	if ip == "" {
+		return nil
+	}
+	ban, err := s.db.GetActiveBan(0, ip)
+	if err != nil {
+		return nil
+	}
+	return ban
+}
+
+// refuseBanned turns away a user who has just logged in if they or their
+// address are banned.
+func (c *Client) refuseBanned() bool {
+	ban, err := c.db.GetActiveBan(c.user.ID, c.remoteIP())
+	if err != nil {
+		return false
+	}
+	c.write("\033[31m" + banNotice(ban) + "\033[0m\n")
+	log.Printf("Refused banned user %s from %s", c.user.Username, c.remoteIP())
+	return true
+}
+
+// banNotice tells a banned visitor why they can't come in.
+func banNotice(ban *storage.Sanction) string {
+	notice := "You are banned from this BBS " + sanctionExpiry(ban)
+	if ban.Reason != "" {
+		notice += ": " + ban.Reason
+	}
+	return notice + "."
+}
+
+// sanctionScope describes where a mute applies.
+func sanctionScope(s *storage.Sanction) string {
+	if s.RoomID == 0 {
+		return "everywhere"
+	}
+	return "in " + s.RoomName
+}
+
+// sanctionExpiry describes how long a sanction lasts.
+func sanctionExpiry(s *storage.Sanction) string {
+	if s.ExpiresAt.IsZero() {
+		return "until further notice"
+	}
+	return "until " + s.ExpiresAt.Format("2006-01-02 15:04")
+}
+
+// parseSanctionDuration reads a span like 30m, 2h, 7d or 2w.
+func parseSanctionDuration(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	switch s[len(s)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, true
+	case 'h':
+		return time.Duration(n) * time.Hour, true
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, true
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// forDuration describes a span given on the command line.
+func forDuration(span string) string {
+	if span == "" {
+		return "until further notice"
+	}
+	return "for " + span
+}
+
+// sanctionTarget looks up the user a moderator wants to act on and checks
+// they outrank them.
+func (c *Client) sanctionTarget(name, action string) (*storage.User, bool) {
+	user, err := c.db.GetUserByName(name)
+	if err != nil {
+		c.write(fmt.Sprintf("No such user '%s'.\n", name))
+		return nil, false
+	}
+	if user.ID == c.user.ID {
+		c.write(fmt.Sprintf("You can't %s yourself.\n", action))
+		return nil, false
+	}
+	if storage.RoleRank(user.Role) >= storage.RoleRank(c.role()) {
+		c.write(fmt.Sprintf("%s is a %s; you can't %s them.\n", user.Username, user.Role, action))
+		return nil, false
+	}
+	return user, true
+}
+
+// addrProtector returns someone other than the moderator connected from
+// ip whom they don't outrank, and so mustn't lock out along with the
+// address, or nil if there is nobody.
+func (c *Client) addrProtector(ip string) *storage.User {
+	rank := storage.RoleRank(c.role())
+	for _, name := range c.server.AddrUsers(ip) {
+		user, err := c.db.GetUserByName(name)
+		if err != nil || user.ID == c.user.ID {
+			continue
+		}
+		if storage.RoleRank(user.Role) >= rank {
+			return user
+		}
+	}
+	return nil
+}
+
+// withReason appends ": reason" when there is one.
+func withReason(text, reason string) string {
+	if reason == "" {
+		return text
+	}
+	return text + ": " + reason
+}
+
+// kick disconnects every session of a user.
+func (c *Client) kick(args []string) {
+	if len(args) == 0 {
+		c.write("Usage: /kick <user> [reason]\n")
+		return
+	}
+	user, ok := c.sanctionTarget(args[0], "kick")
+	if !ok {
+		return
+	}
+	reason := strings.Join(args[1:], " ")
+
+	notice := withReason(fmt.Sprintf("*** You have been kicked by %s", c.user.Username), reason)
+	if c.server.DisconnectUser(user.ID, "\033[31m"+notice+" ***\033[0m\n") == 0 {
+		c.write(fmt.Sprintf("%s isn't online.\n", user.Username))
+		return
+	}
+	if _, err := c.db.AddSanction(storage.Sanction{Kind: storage.SanctionKick, UserID: user.ID,
+		Reason: reason, IssuedBy: c.user.ID}); err != nil {
+		log.Printf("Failed to record kick of %s: %v", user.Username, err)
+	}
+	log.Printf("%s kicked %s", c.user.Username, user.Username)
+	c.server.BroadcastGlobal(fmt.Sprintf("\033[31m*** %s ***\033[0m\n",
+		withReason(fmt.Sprintf("%s was kicked by %s", user.Username, c.user.Username), reason)))
+}
+
+// mute stops a user sending chat messages, everywhere or in the current
+// room, for a while or until unmuted.
+func (c *Client) mute(args []string) {
+	if len(args) == 0 {
+		c.write("Usage: /mute <user> [30m|2h|7d] [here] [reason]\n")
+		return
+	}
+	user, ok := c.sanctionTarget(args[0], "mute")
+	if !ok {
+		return
+	}
+
+	sanction := storage.Sanction{Kind: storage.SanctionMute, UserID: user.ID, IssuedBy: c.user.ID}
+	var span string
+	rest := args[1:]
+	for len(rest) > 0 {
+		if d, ok := parseSanctionDuration(rest[0]); ok && span == "" {
+			span = rest[0]
+			sanction.ExpiresAt = time.Now().Add(d)
+		} else if strings.ToLower(rest[0]) == "here" && c.currentRoom != nil && sanction.RoomID == 0 {
+			sanction.RoomID, sanction.RoomName = c.currentRoom.ID, c.currentRoom.Name
+		} else {
+			break
+		}
+		rest = rest[1:]
+	}
+	sanction.Reason = strings.Join(rest, " ")
+
+	if _, err := c.db.AddSanction(sanction); err != nil {
+		c.write("Failed to mute.\n")
+		return
+	}
+	log.Printf("%s muted %s %s", c.user.Username, user.Username, sanctionScope(&sanction))
+	c.write(fmt.Sprintf("\033[32m%s is muted %s %s.\033[0m\n", user.Username, sanctionScope(&sanction), forDuration(span)))
+	c.server.SendToUser(user.ID, fmt.Sprintf("\033[31m*** %s ***\033[0m\n", withReason(fmt.Sprintf("%s muted you %s %s",
+		c.user.Username, sanctionScope(&sanction), forDuration(span)), sanction.Reason)))
+}
+
+// unmute lifts every mute on a user.
+func (c *Client) unmute(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /unmute <user>\n")
+		return
+	}
+	user, ok := c.sanctionTarget(args[0], "unmute")
+	if !ok {
+		return
+	}
+
+	n, err := c.db.LiftSanctions(storage.SanctionMute, user.ID, "", c.user.ID)
+	if err != nil {
+		c.write("Failed to unmute.\n")
+		return
+	}
+	if n == 0 {
+		c.write(fmt.Sprintf("%s isn't muted.\n", user.Username))
+		return
+	}
+	c.write(fmt.Sprintf("\033[32m%s can chat again.\033[0m\n", user.Username))
+	c.server.SendToUser(user.ID, fmt.Sprintf("\033[32m*** %s unmuted you ***\033[0m\n", c.user.Username))
+}
+
+// ban keeps a user or an IP address out, for a while or until unbanned.
+// With the ip option a user's current addresses are banned as well.
+// Addresses shared with someone the moderator doesn't outrank are spared.
+func (c *Client) ban(args []string) {
+	if len(args) == 0 {
+		c.write("Usage: /ban <user|ip> [30m|2h|7d] [ip] [reason]\n")
+		return
+	}
+
+	sanction := storage.Sanction{Kind: storage.SanctionBan, IssuedBy: c.user.ID}
+	var user *storage.User
+	if ip := net.ParseIP(args[0]); ip != nil {
+		sanction.IP = ip.String()
+		if other := c.addrProtector(sanction.IP); other != nil {
+			c.write(fmt.Sprintf("%s is a %s connected from %s; you can't ban that address.\n",
+				other.Username, other.Role, sanction.IP))
+			return
+		}
+		if sanction.IP == c.remoteIP() {
+			c.write("That's your own address.\n")
+			return
+		}
+	} else {
+		var ok bool
+		if user, ok = c.sanctionTarget(args[0], "ban"); !ok {
+			return
+		}
+		sanction.UserID = user.ID
+	}
+
+	var span string
+	var byAddr bool
+	rest := args[1:]
+	for len(rest) > 0 {
+		if d, ok := parseSanctionDuration(rest[0]); ok && span == "" {
+			span = rest[0]
+			sanction.ExpiresAt = time.Now().Add(d)
+		} else if strings.ToLower(rest[0]) == "ip" && user != nil && !byAddr {
+			byAddr = true
+		} else {
+			break
+		}
+		rest = rest[1:]
+	}
+	sanction.Reason = strings.Join(rest, " ")
+
+	// One row per address, each tied to the user so unban lifts them all
+	sanctions := []storage.Sanction{sanction}
+	if byAddr {
+		addrs := c.server.UserAddrs(user.ID)
+		if len(addrs) == 0 {
+			c.write(fmt.Sprintf("%s isn't online, so only the account is banned.\n", user.Username))
+		}
+		for _, addr := range addrs {
+			if other := c.addrProtector(addr); other != nil {
+				c.write(fmt.Sprintf("Not banning %s: %s is a %s connected from it.\n", addr, other.Username, other.Role))
+				continue
+			}
+			if addr == c.remoteIP() {
+				c.write(fmt.Sprintf("Not banning %s: it's your own address.\n", addr))
+				continue
+			}
+			if sanctions[len(sanctions)-1].IP != "" {
+				sanctions = append(sanctions, sanction)
+			}
+			sanctions[len(sanctions)-1].IP = addr
+		}
+	}
+	for _, s := range sanctions {
+		if _, err := c.db.AddSanction(s); err != nil {
+			c.write("Failed to ban.\n")
+			return
+		}
+	}
+
+	target := sanction.IP
+	if user != nil {
+		target = user.Username
+	}
+	notice := fmt.Sprintf("\033[31m*** %s ***\033[0m\n", withReason(fmt.Sprintf("You have been banned by %s %s",
+		c.user.Username, forDuration(span)), sanction.Reason))
+	kicked := 0
+	if user != nil {
+		kicked += c.server.DisconnectUser(user.ID, notice)
+	}
+	for _, s := range sanctions {
+		if s.IP != "" {
+			kicked += c.server.DisconnectAddr(s.IP, notice)
+		}
+	}
+	log.Printf("%s banned %s", c.user.Username, target)
+	c.write(fmt.Sprintf("\033[32m%s is banned %s.\033[0m\n", target, forDuration(span)))
+	if kicked > 0 {
+		c.server.BroadcastGlobal(fmt.Sprintf("\033[31m*** %s ***\033[0m\n",
+			withReason(fmt.Sprintf("%s was banned by %s", target, c.user.Username), sanction.Reason)))
+	}
+}
+
+// unban lifts every ban on a user or an IP address.
+func (c *Client) unban(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /unban <user|ip>\n")
+		return
+	}
+
+	var userID int
+	var ip, target string
+	if parsed := net.ParseIP(args[0]); parsed != nil {
+		ip, target = parsed.String(), parsed.String()
+	} else {
+		user, err := c.db.GetUserByName(args[0])
+		if err != nil {
+			c.write(fmt.Sprintf("No such user '%s'.\n", args[0]))
+			return
+		}
+		userID, target = user.ID, user.Username
+	}
+
+	n, err := c.db.LiftSanctions(storage.SanctionBan, userID, ip, c.user.ID)
+	if err != nil {
+		c.write("Failed to unban.\n")
+		return
+	}
+	if n == 0 {
+		c.write(fmt.Sprintf("%s isn't banned.\n", target))
+		return
+	}
+	log.Printf("%s unbanned %s", c.user.Username, target)
+	c.write(fmt.Sprintf("\033[32m%s is no longer banned.\033[0m\n", target))
+}
+
+// showSanctions lists the mutes and bans in force, or everything ever
+// issued against one user.
+func (c *Client) showSanctions(args []string) {
+	var sanctions []storage.Sanction
+	var err error
+	var title string
+	switch len(args) {
+	case 0:
+		title = "Active mutes and bans"
+		sanctions, err = c.db.GetActiveSanctions()
+	case 1:
+		user, lookupErr := c.db.GetUserByName(args[0])
+		if lookupErr != nil {
+			c.write(fmt.Sprintf("No such user '%s'.\n", args[0]))
+			return
+		}
+		title = "Record of " + user.Username
+		sanctions, err = c.db.GetUserSanctions(user.ID)
+	default:
+		c.write("Usage: /sanctions [user]\n")
+		return
+	}
+	if err != nil {
+		c.write("Error loading sanctions.\n")
+		return
+	}
+	if len(sanctions) == 0 {
+		c.write("Nothing on record.\n")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[36m%s:\033[0m\n", title))
+	c.write(c.separator("-", 60) + "\n")
+	for i := range sanctions {
+		c.writeWrapped(formatSanction(&sanctions[i]) + "\n")
+	}
+	c.write(c.separator("-", 60) + "\n\n")
+}
+
+// formatSanction renders one line of the sanctions listing.
+func formatSanction(s *storage.Sanction) string {
+	target := s.Username
+	switch {
+	case target == "":
+		target = s.IP
+	case s.IP != "":
+		target += " (" + s.IP + ")"
+	}
+
+	line := fmt.Sprintf("\033[90m#%d %s\033[0m %s \033[33m%s\033[0m", s.ID, s.CreatedAt.Format("2006-01-02 15:04"), s.Kind, target)
+	if s.Kind == storage.SanctionMute {
+		line += " " + sanctionScope(s)
+	}
+	switch {
+	case s.Kind == storage.SanctionKick:
+	case !s.LiftedAt.IsZero():
+		line += " (lifted " + s.LiftedAt.Format("2006-01-02 15:04") + ")"
+	case !s.ExpiresAt.IsZero() && s.ExpiresAt.Before(time.Now()):
+		line += " (expired)"
+	default:
+		line += " " + sanctionExpiry(s)
+	}
+	return withReason(line+" by "+s.IssuerName, s.Reason)
+}
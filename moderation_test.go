@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"bbs/internal/storage"
+)
+
+func TestKickMuteBan(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+
+	bob.send("/kick carol")
+	bob.expect("You don't have permission to use 'kick'.")
+
+	alice.send("/role carol moderator")
+	alice.expect("carol is now a moderator.")
+	carol.expect("*** alice made you a moderator ***")
+	carol.send("/kick alice")
+	carol.expect("alice is a sysop; you can't kick them.")
+
+	carol.send("/mute bob 10m here flooding")
+	carol.expect("bob is muted in General for 10m.")
+	bob.expect("*** carol muted you in General for 10m: flooding ***")
+	bob.send("hello?")
+	bob.expect("You are muted in General until")
+	carol.expectNothing("hello?")
+
+	carol.expectPrompt()
+	carol.send("/sanctions")
+	out := carol.expectPrompt()
+	if !strings.Contains(out, "mute bob in General until") || !strings.Contains(out, "by carol:") || !strings.Contains(out, "flooding") {
+		t.Errorf("sanctions: %q", out)
+	}
+
+	carol.send("/unmute bob")
+	carol.expect("bob can chat again.")
+	bob.expect("*** carol unmuted you ***")
+	bob.send("sorry")
+	alice.expect("bob: sorry")
+
+	alice.send("/kick bob enough")
+	bob.expect("*** You have been kicked by alice: enough ***")
+	bob.expectClosed()
+	carol.expect("*** bob was kicked by alice: enough ***")
+
+	bob = s.login("bob", "secret")
+	alice.send("/ban bob 1h rude")
+	alice.expect("bob is banned for 1h.")
+	bob.expect("*** You have been banned by alice for 1h: rude ***")
+	bob.expectClosed()
+
+	bob = s.dial()
+	bob.expect("(L)ogin or (R)egister?")
+	bob.send("l")
+	bob.expect("Username:")
+	bob.send("bob")
+	bob.expect("Password:")
+	bob.send("secret")
+	bob.expect("You are banned from this BBS until")
+	bob.expect(": rude.")
+	bob.expectClosed()
+
+	alice.expect("*** bob was banned by alice: rude ***")
+	alice.expectPrompt()
+	alice.send("/sanctions bob")
+	out = alice.expectPrompt()
+	for _, want := range []string{"ban bob until", "kick bob by alice: enough", "mute bob in General (lifted"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("sanctions bob: missing %q in %q", want, out)
+		}
+	}
+
+	alice.send("/unban bob")
+	alice.expect("bob is no longer banned.")
+	s.login("bob", "secret")
+}
+
+func TestMutedCannotEdit(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	bob.send("hello all")
+	alice.expect("bob: hello all")
+	alice.send("/mute bob 10m")
+	alice.expect("bob is muted everywhere for 10m.")
+	bob.expect("*** alice muted you")
+
+	bob.send("/edit last buy my stuff")
+	bob.expect("You are muted everywhere until")
+	alice.expectNothing("buy my stuff")
+}
+
+func TestIPBan(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	s.register("bob", "secret")
+
+	// Everyone in the test shares 127.0.0.1, so the moderator's own
+	// address is left out
+	alice.send("/ban bob ip")
+	alice.expect("Not banning 127.0.0.1: it's your own address.")
+	alice.expect("bob is banned until further notice.")
+	alice.send("/ban 127.0.0.1")
+	alice.expect("That's your own address.")
+	alice.send("/unban bob")
+	alice.expect("bob is no longer banned.")
+
+	user, _ := s.db.GetUserByName("alice")
+	if _, err := s.db.AddSanction(storage.Sanction{Kind: storage.SanctionBan, IP: "127.0.0.1",
+		Reason: "proxy abuse", IssuedBy: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	c := s.dial()
+	c.expect("You are banned from this BBS until further notice: proxy abuse.")
+	c.expectClosed()
+
+	alice.send("/unban 127.0.0.1")
+	alice.expect("127.0.0.1 is no longer banned.")
+	s.login("bob", "secret")
+}
+
+func TestIPBanSparesHigherRanks(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	alice.send("/role carol moderator")
+	alice.expect("carol is now a moderator.")
+
+	// alice shares the address, and a moderator can't lock out a sysop
+	carol.send("/ban 127.0.0.1")
+	carol.expect("alice is a sysop connected from 127.0.0.1; you can't ban that address.")
+	carol.send("/ban bob ip")
+	carol.expect("Not banning 127.0.0.1: alice is a sysop connected from it.")
+	carol.expect("bob is banned until further notice.")
+
+	carol.expectPrompt()
+	carol.send("/sanctions")
+	if out := carol.expectPrompt(); strings.Contains(out, "127.0.0.1") {
+		t.Errorf("address banned: %q", out)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// What happens to a message for a client whose send queue is full
+const (
+	OverflowDropOldest = "drop_oldest" // make room by dropping the oldest queued message
+	OverflowDisconnect = "disconnect"  // hang up on the client
+)
+
+// OverflowPolicies lists every limits.send_overflow setting.
+var OverflowPolicies = []string{OverflowDropOldest, OverflowDisconnect}
+
+// How long a client that is going away gets to receive what is still
+// queued for it
+const flushTimeout = 5 * time.Second
+
+// queueMetrics counts send queue trouble across all clients since startup.
+type queueMetrics struct {
+	dropped      atomic.Int64 // messages dropped under drop_oldest
+	disconnected atomic.Int64 // clients hung up on under disconnect
+	peak         atomic.Int64 // deepest any queue has been
+}
+
+// observe records a queue depth for the peak.
+func (m *queueMetrics) observe(depth int) {
+	for {
+		peak := m.peak.Load()
+		if int64(depth) <= peak || m.peak.CompareAndSwap(peak, int64(depth)) {
+			return
+		}
+	}
+}
+
+// startWriter starts the goroutine that drains the send queue onto the
+// connection. It stops after a write fails or the client hangs up, closing
+// the connection either way.
+func (c *Client) startWriter() {
+	go func() {
+		defer close(c.writerDone)
+		defer c.conn.Close()
+
+		for {
+			select {
+			case message := <-c.outbox:
+				if !c.flush(message) {
+					return
+				}
+			case <-c.hangUp:
+				// Send what's left, but don't wait forever on a stalled peer
+				c.conn.SetWriteDeadline(time.Now().Add(flushTimeout))
+				for {
+					select {
+					case message := <-c.outbox:
+						if !c.flush(message) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// flush writes one queued message, after a notice if messages were dropped
+// since the last one.
+func (c *Client) flush(message string) bool {
+	if n := c.pendingDrops.Swap(0); n > 0 {
+		message = fmt.Sprintf("\033[31m*** %d messages dropped because your connection fell behind ***\033[0m\n", n) + message
+	}
+	_, err := c.conn.Write([]byte(message))
+	return err == nil
+}
+
+// stopWriter hangs up and waits, up to flushTimeout, for the queue to be
+// written out.
+func (c *Client) stopWriter() {
+	c.hangUpOnce.Do(func() { close(c.hangUp) })
+	select {
+	case <-c.writerDone:
+	case <-time.After(flushTimeout):
+	}
+}
+
+// enqueue queues output from the client's own goroutine, waiting for room
+// if the queue is full. Only this client is held up by that.
+func (c *Client) enqueue(message string) {
+	select {
+	case c.outbox <- message:
+		c.server.queues.observe(len(c.outbox))
+	case <-c.writerDone:
+	}
+}
+
+// deliver queues a message from another goroutine, such as a broadcast,
+// word-wrapped like writeWrapped. It never blocks: if the queue is full
+// limits.send_overflow decides what gives.
+func (c *Client) deliver(message string) {
+	width, _ := c.windowSize()
+	message = wrapText(message, width, chatIndent)
+
+	for {
+		select {
+		case c.outbox <- message:
+			c.server.queues.observe(len(c.outbox))
+			return
+		default:
+		}
+
+		if c.server.config.Limits.SendOverflow == OverflowDisconnect {
+			c.overflowOnce.Do(func() {
+				log.Printf("Disconnecting %s: send queue full", c.user.Username)
+				c.server.queues.disconnected.Add(1)
+				// The caller may hold the server's lock, and closing a
+				// connection can block on a peer that isn't reading
+				go c.conn.Close()
+			})
+			return
+		}
+		select {
+		case <-c.outbox:
+			c.pendingDrops.Add(1)
+			c.dropped.Add(1)
+			c.server.queues.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// hangUpAfter closes the connection once what's queued so far, such as a
+// message saying why, has been written.
+func (c *Client) hangUpAfter() {
+	c.hangUpOnce.Do(func() { close(c.hangUp) })
+	time.AfterFunc(flushTimeout, func() { c.conn.Close() })
+}
+
+// queueStat is one client's send queue as shown by showQueues.
+type queueStat struct {
+	name    string
+	depth   int
+	dropped int64
+}
+
+// queueStats returns every logged in client's send queue, fullest first.
+func (s *BBSServer) queueStats() []queueStat {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stats := make([]queueStat, 0, len(s.clients))
+	for client := range s.clients {
+		stats = append(stats, queueStat{client.user.Username, len(client.outbox), client.dropped.Load()})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].depth != stats[j].depth {
+			return stats[i].depth > stats[j].depth
+		}
+		return stats[i].name < stats[j].name
+	})
+	return stats
+}
+
+// showQueues shows how far behind each client's connection is.
+func (c *Client) showQueues() {
+	limits := c.server.config.Limits
+	stats := c.server.queueStats()
+
+	c.write(fmt.Sprintf("\033[36mSend queues (%d sessions, %d messages each, on overflow: %s):\033[0m\n",
+		len(stats), limits.SendQueue, limits.SendOverflow))
+	c.write(c.separator("-", 60) + "\n")
+	for _, stat := range stats {
+		c.write(fmt.Sprintf("\033[33m%-20s\033[0m %5d queued %8d dropped\n", stat.name, stat.depth, stat.dropped))
+	}
+	c.write(c.separator("-", 60) + "\n")
+
+	queues := &c.server.queues
+	c.write(fmt.Sprintf("Since startup: deepest queue %d, %d messages dropped, %d sessions disconnected.\n\n",
+		queues.peak.Load(), queues.dropped.Load(), queues.disconnected.Load()))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+// pipeTerminal is a Terminal on one end of a net.Pipe, whose writes block
+// until the other end reads: the slowest reader there is.
+type pipeTerminal struct {
+	net.Conn
+}
+
+func (pipeTerminal) SuppressEcho(bool) {}
+
+// stalledClient returns a client whose peer isn't reading, and the peer.
+func stalledClient(t *testing.T, configure func(*Config)) (*Client, net.Conn) {
+	t.Helper()
+
+	config := DefaultConfig()
+	configure(config)
+	server, peer := net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+
+	c := NewTerminalClient(pipeTerminal{server}, nil, NewBBSServer(nil, config))
+	c.user = &storage.User{Username: "slowpoke"}
+	c.startWriter()
+	return c, peer
+}
+
+// deliverAll delivers messages, failing the test if that blocks.
+func deliverAll(t *testing.T, c *Client, messages ...string) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		for _, message := range messages {
+			c.deliver(message)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("deliver blocked on a client that isn't reading")
+	}
+}
+
+func TestSendQueueDropsOldest(t *testing.T) {
+	c, peer := stalledClient(t, func(config *Config) {
+		config.Limits.SendQueue = 3
+	})
+
+	var messages []string
+	for i := 0; i < 20; i++ {
+		messages = append(messages, "message "+string(rune('a'+i))+"\n")
+	}
+	deliverAll(t, c, messages...)
+	// Three queued, and maybe one taken by the writer
+	if n := c.dropped.Load(); n < 16 || c.server.queues.dropped.Load() != n {
+		t.Errorf("dropped: client %d, server %d", n, c.server.queues.dropped.Load())
+	}
+
+	// The peer catches up: the newest messages survive, after a notice
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var out strings.Builder
+	buf := make([]byte, 1024)
+	for !strings.Contains(out.String(), "message t") {
+		n, err := peer.Read(buf)
+		if err != nil {
+			t.Fatalf("reading: %v after %q", err, out.String())
+		}
+		out.Write(buf[:n])
+	}
+	if !strings.Contains(out.String(), "messages dropped because your connection fell behind") {
+		t.Errorf("no drop notice: %q", out.String())
+	}
+	if strings.Contains(out.String(), "message j") {
+		t.Errorf("an old message survived: %q", out.String())
+	}
+}
+
+func TestSendQueueDisconnects(t *testing.T) {
+	c, peer := stalledClient(t, func(config *Config) {
+		config.Limits.SendQueue = 1
+		config.Limits.SendOverflow = OverflowDisconnect
+	})
+
+	deliverAll(t, c, "one\n", "two\n", "three\n", "four\n")
+	if n := c.server.queues.disconnected.Load(); n != 1 {
+		t.Errorf("disconnected = %d, want 1", n)
+	}
+
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(peer); err != nil {
+		t.Errorf("connection not closed: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+
+	"bbs/internal/storage"
+)
+
+// commandRoles is the least trusted role allowed to run each command.
+// Commands not listed are open to everyone.
+var commandRoles = map[string]string{
+	"revisions": storage.RoleModerator,
+	"kick":      storage.RoleModerator,
+	"mute":      storage.RoleModerator,
+	"unmute":    storage.RoleModerator,
+	"ban":       storage.RoleModerator,
+	"unban":     storage.RoleModerator,
+	"sanctions": storage.RoleModerator,
+	"pin":       storage.RoleModerator,
+	"unpin":     storage.RoleModerator,
+	"role":      storage.RoleSysop,
+	"accounts":  storage.RoleSysop,
+	"queues":    storage.RoleSysop,
+	"broadcast": storage.RoleSysop,
+	"setmotd":   storage.RoleSysop,
+}
+
+// refreshRole reloads the user's role from the database. It runs before
+// each command so promotions and demotions by others apply at once,
+// without a query for every check the command makes.
+func (c *Client) refreshRole() {
+	user, err := c.db.GetUserByName(c.user.Username)
+	if err != nil {
+		c.userRole = storage.RoleUser
+		return
+	}
+	c.userRole = user.Role
+}
+
+// role returns the user's role as of the current command.
+func (c *Client) role() string {
+	return c.userRole
+}
+
+// hasRole reports whether the user is at least as trusted as role.
+func (c *Client) hasRole(role string) bool {
+	return storage.RoleRank(c.role()) >= storage.RoleRank(role)
+}
+
+// allowed reports whether the user may run command.
+func (c *Client) allowed(command string) bool {
+	required, ok := commandRoles[command]
+	return !ok || c.hasRole(required)
+}
+
+// applyConfiguredRole makes the user sysop if the config names them as one.
+func (c *Client) applyConfiguredRole() {
+	for _, name := range c.server.config.Roles.Sysops {
+		if name != c.user.Username {
+			continue
+		}
+		if c.user.Role != storage.RoleSysop {
+			if err := c.db.SetUserRole(c.user.ID, storage.RoleSysop); err != nil {
+				log.Printf("Failed to make %s sysop: %v", c.user.Username, err)
+				return
+			}
+			log.Printf("User %s is now sysop (roles.sysops)", c.user.Username)
+		}
+		return
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Terminal size assumed until the client tells us otherwise
+const (
+	defaultTermWidth  = 80
+	defaultTermHeight = 24
+)
+
+// Continuation lines of a wrapped chat message line up after "[15:04] "
+const chatIndent = 8
+
+// visibleLen returns the number of terminal columns s occupies, ignoring
+// ANSI escape sequences.
+func visibleLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\033' {
+			i = skipEscape(s, i)
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// skipEscape returns the index just past the escape sequence starting at i.
+func skipEscape(s string, i int) int {
+	i++
+	if i < len(s) && s[i] == '[' {
+		i++
+		for i < len(s) && (s[i] < 0x40 || s[i] > 0x7e) {
+			i++
+		}
+	}
+	return i + 1
+}
+
+// truncateText shortens plain text to at most width columns, marking the
+// cut with "...".
+func truncateText(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 3 {
+		return string([]rune(s)[:width])
+	}
+	return string([]rune(s)[:width-3]) + "..."
+}
+
+// wrapText word-wraps every line of s to width columns, indenting
+// continuation lines by indent spaces. ANSI escapes are kept intact and
+// don't count towards the width. Words longer than a line are split.
+func wrapText(s string, width, indent int) string {
+	if width <= indent+10 {
+		return s
+	}
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if visibleLen(line) > width {
+			lines[i] = wrapLine(line, width, indent)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func wrapLine(line string, width, indent int) string {
+	var out strings.Builder
+	pad := strings.Repeat(" ", indent)
+	col := 0
+	lineStart := 0
+
+	for _, word := range strings.SplitAfter(line, " ") {
+		wordLen := visibleLen(strings.TrimRight(word, " "))
+		if col > lineStart && col+wordLen > width {
+			out.WriteString("\n" + pad)
+			col, lineStart = indent, indent
+		}
+
+		// Hard-break anything that still doesn't fit
+		for i := 0; i < len(word); {
+			if word[i] == '\033' {
+				end := skipEscape(word, i)
+				if end > len(word) {
+					end = len(word)
+				}
+				out.WriteString(word[i:end])
+				i = end
+				continue
+			}
+			if col >= width {
+				if word[i] == ' ' {
+					// A space past the edge would wrap the cursor on
+					// its own and leave a blank line after the break
+					i++
+					continue
+				}
+				out.WriteString("\n" + pad)
+				col, lineStart = indent, indent
+			}
+			_, size := utf8.DecodeRuneInString(word[i:])
+			out.WriteString(word[i : i+size])
+			i += size
+			col++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVisibleLen(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"\033[33malice:\033[0m hi", 9},
+		{"\033[7m--More--\033[0m", 8},
+		{"café", 4},
+		{"\033[1;32mÜber\033[0m naïve", 10},
+		{"日本語", 3},
+	}
+	for _, tt := range tests {
+		if got := visibleLen(tt.s); got != tt.want {
+			t.Errorf("visibleLen(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"a little too long", 10, "a littl..."},
+		{"crème brûlée", 8, "crème..."},
+		{"日本語のテキスト", 5, "日本..."},
+		{"naïve", 3, "naï"},
+		{"anything", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		width  int
+		indent int
+		want   string
+	}{
+		{
+			name:  "fits",
+			s:     "one two three\n",
+			width: 20,
+			want:  "one two three\n",
+		},
+		{
+			name:   "words, dropping a space that would overhang",
+			s:      "[15:04] alice: the quick brown fox jumps over the lazy dog\n",
+			width:  24,
+			indent: 8,
+			want:   "[15:04] alice: the quick\n        brown fox jumps \n        over the lazy \n        dog\n",
+		},
+		{
+			name:   "escapes take no room",
+			s:      "\033[33malice:\033[0m \033[1mbold\033[0m words that wrap here\n",
+			width:  20,
+			indent: 2,
+			want:   "\033[33malice:\033[0m \033[1mbold\033[0m words \n  that wrap here\n",
+		},
+		{
+			name:   "multibyte runes count once",
+			s:      "über naïve café crème brûlée\n",
+			width:  16,
+			indent: 2,
+			want:   "über naïve café \n  crème brûlée\n",
+		},
+		{
+			name:   "long words split",
+			s:      "see https://example.com/a/very/long/path\n",
+			width:  16,
+			indent: 2,
+			want:   "see \n  https://exampl\n  e.com/a/very/l\n  ong/path\n",
+		},
+		{
+			name:   "too narrow to bother",
+			s:      "left alone because the window is tiny",
+			width:  10,
+			indent: 2,
+			want:   "left alone because the window is tiny",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapText(tt.s, tt.width, tt.indent)
+			if got != tt.want {
+				t.Errorf("wrapText = %q, want %q", got, tt.want)
+			}
+			for _, line := range strings.Split(got, "\n") {
+				if visibleLen(line) > tt.width && tt.width > tt.indent+10 {
+					t.Errorf("line %q is wider than %d", line, tt.width)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirstUserIsSysop(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	bob.send("/accounts")
+	bob.expect("You don't have permission to use 'accounts'.")
+	bob.send("/revisions 1")
+	bob.expect("You don't have permission to use 'revisions'.")
+	bob.expectPrompt()
+	bob.send("help")
+	if out := bob.expectPrompt(); strings.Contains(out, "Sysop commands") || strings.Contains(out, "Moderator commands") {
+		t.Errorf("help for a user: %q", out)
+	}
+
+	alice.send("/accounts")
+	out := alice.expectPrompt()
+	if !strings.Contains(out, "alice                sysop") || !strings.Contains(out, "bob                  user") {
+		t.Errorf("accounts: %q", out)
+	}
+
+	alice.send("/role bob moderator")
+	alice.expect("bob is now a moderator.")
+	bob.expect("*** alice made you a moderator ***")
+
+	// The new role applies without logging in again
+	bob.send("/revisions 1")
+	bob.expect("Message 1 has never been edited or deleted.")
+	bob.send("/role alice user")
+	bob.expect("You don't have permission to use 'role'.")
+	bob.expectPrompt()
+	bob.send("help")
+	if out := bob.expectPrompt(); strings.Contains(out, "Sysop commands") || !strings.Contains(out, "Moderator commands") {
+		t.Errorf("help for a moderator: %q", out)
+	}
+
+	alice.send("/role alice user")
+	alice.expect("You can't change your own role.")
+	alice.send("/role bob wizard")
+	alice.expect("Unknown role 'wizard'.")
+
+	alice.send("/broadcast server restarting soon")
+	bob.expect("*** SYSOP alice: server restarting soon ***")
+}
+
+func TestCommandWordsAreChat(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	// Without a "/" only the original commands are commands
+	for _, line := range []string{"new phone who dis", "pin the date on the calendar", "Ban on ball games in the yard", "search me"} {
+		bob.send(line)
+		alice.expect("bob: " + line)
+	}
+	alice.send("ban everything")
+	bob.expect("alice: ban everything")
+
+	// With one the command runs, or is refused to those without the role
+	bob.send("/pin the date")
+	bob.expect("You don't have permission to use 'pin'.")
+	alice.send("/ban")
+	alice.expect("Usage: /ban")
+	alice.send("/sanctions")
+	alice.expect("Nothing on record.")
+	bob.expectNothing("alice: ")
+}
+
+func TestConfiguredSysop(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Roles.Sysops = []string{"carol"}
+	})
+	s.register("alice", "secret")
+	carol := s.register("carol", "secret")
+
+	carol.send("/setmotd")
+	carol.expect("End with a line containing only '.'")
+	carol.send("Maintenance tonight.")
+	carol.send(".")
+	carol.expect("MOTD updated.")
+
+	carol.send("motd")
+	carol.expect("Maintenance tonight.")
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+// How often the server looks for idle rooms to archive
+const roomSweepInterval = time.Hour
+
+// canEnter reports whether the user may join a room without a password:
+// it's public, they own or are a member of it, or they're a sysop.
+func (c *Client) canEnter(room *storage.ChatRoom) bool {
+	if room.Access == storage.RoomPublic || c.ownsRoom(room) {
+		return true
+	}
+	member, err := c.db.IsRoomMember(room.ID, c.user.ID)
+	return err == nil && member
+}
+
+// ownsRoom reports whether the user may manage a room. Sysops manage
+// every room.
+func (c *Client) ownsRoom(room *storage.ChatRoom) bool {
+	return (room.OwnerID != 0 && room.OwnerID == c.user.ID) || c.hasRole(storage.RoleSysop)
+}
+
+// unlockRoom asks for a password room's password and makes the user a
+// member if it's right, so they aren't asked again.
+func (c *Client) unlockRoom(room *storage.ChatRoom) bool {
+	c.write(fmt.Sprintf("Password for %s: ", room.Name))
+	password, ok := c.readPassword()
+	if !ok || password == "" {
+		return false
+	}
+	if match, err := c.db.CheckRoomPassword(room.ID, password); err != nil || !match {
+		c.write("Wrong password.\n")
+		return false
+	}
+	if err := c.db.AddRoomMember(room.ID, c.user.ID, 0); err != nil {
+		c.write("Failed to join room.\n")
+		return false
+	}
+	return true
+}
+
+// leaveLostRoom moves the user back to the default room if they may no
+// longer enter the one they're in, and reports whether it did. Anything
+// that shows or adds to the current room checks it first. Losing a
+// membership or a room going private moves people out at once (see
+// EvictFromRoom); this catches the rest, such as a sysop being demoted.
+func (c *Client) leaveLostRoom() bool {
+	// The client's copy may predate a change of access
+	room, err := c.db.GetChatRoom(c.currentRoom.Name)
+	if err != nil {
+		room = c.currentRoom
+	}
+	if c.canEnter(room) {
+		return false
+	}
+	c.write(fmt.Sprintf("\033[31mYou can no longer enter %s.\033[0m\n", room.Name))
+	if room, err := c.db.GetChatRoom(c.server.config.Seed.DefaultRoom); err == nil {
+		c.joinRoom(room.Name)
+	}
+	return true
+}
+
+// enterableRoom looks up a room the user wants to join or follow, asking
+// for the password of a password room they aren't a member of yet.
+// Private rooms are hidden from outsiders.
+func (c *Client) enterableRoom(name string) (*storage.ChatRoom, bool) {
+	room, err := c.db.GetChatRoom(name)
+	if err != nil || (room.Access == storage.RoomPrivate && !c.canEnter(room)) {
+		c.write(fmt.Sprintf("Room '%s' not found.\n", name))
+		return nil, false
+	}
+	if !c.canEnter(room) && !c.unlockRoom(room) {
+		return nil, false
+	}
+	return room, true
+}
+
+// managedRoom returns the room named by args[i], or the current room if
+// there are fewer args, provided the user owns it.
+func (c *Client) managedRoom(args []string, i int) (*storage.ChatRoom, bool) {
+	room := c.currentRoom
+	if len(args) > i {
+		var err error
+		if room, err = c.db.GetChatRoom(args[i]); err != nil || !c.canEnter(room) {
+			c.write(fmt.Sprintf("Room '%s' not found.\n", args[i]))
+			return nil, false
+		}
+	}
+	if room == nil {
+		c.write("You are not in a chat room.\n")
+		return nil, false
+	}
+	if !c.ownsRoom(room) {
+		c.write(fmt.Sprintf("You don't own %s.\n", room.Name))
+		return nil, false
+	}
+	return room, true
+}
+
+// invite lets a user into a private or password room.
+func (c *Client) invite(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		c.write("Usage: /invite <user> [room]\n")
+		return
+	}
+	room, ok := c.managedRoom(args, 1)
+	if !ok {
+		return
+	}
+	user, err := c.db.GetUserByName(args[0])
+	if err != nil {
+		c.write(fmt.Sprintf("No such user '%s'.\n", args[0]))
+		return
+	}
+
+	if err := c.db.AddRoomMember(room.ID, user.ID, c.user.ID); err != nil {
+		c.write("Failed to invite.\n")
+		return
+	}
+	c.write(fmt.Sprintf("\033[32m%s can now join %s.\033[0m\n", user.Username, room.Name))
+	c.server.SendToUser(user.ID, fmt.Sprintf("\033[32m*** %s invited you to %s; type 'join %s' ***\033[0m\n",
+		c.user.Username, room.Name, room.Name))
+}
+
+// uninvite takes a user off a room's member list. Unless they may still
+// enter it, their sessions leave the room at once.
+func (c *Client) uninvite(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		c.write("Usage: /uninvite <user> [room]\n")
+		return
+	}
+	room, ok := c.managedRoom(args, 1)
+	if !ok {
+		return
+	}
+	user, err := c.db.GetUserByName(args[0])
+	if err != nil {
+		c.write(fmt.Sprintf("No such user '%s'.\n", args[0]))
+		return
+	}
+
+	switch err := c.db.RemoveRoomMember(room.ID, user.ID); err {
+	case nil:
+	case sql.ErrNoRows:
+		c.write(fmt.Sprintf("%s isn't a member of %s.\n", user.Username, room.Name))
+		return
+	default:
+		c.write("Failed to uninvite.\n")
+		return
+	}
+	c.write(fmt.Sprintf("\033[32m%s is no longer a member of %s.\033[0m\n", user.Username, room.Name))
+	c.server.SendToUser(user.ID, fmt.Sprintf("\033[31m*** %s removed you from %s ***\033[0m\n", c.user.Username, room.Name))
+	c.evictLosers(room, fmt.Sprintf("You can no longer enter %s", room.Name))
+}
+
+// showMembers lists who may enter a room.
+func (c *Client) showMembers(args []string) {
+	room := c.currentRoom
+	if len(args) > 0 {
+		var err error
+		if room, err = c.db.GetChatRoom(args[0]); err != nil || !c.canEnter(room) {
+			c.write(fmt.Sprintf("Room '%s' not found.\n", args[0]))
+			return
+		}
+	}
+	if room == nil {
+		c.write("You are not in a chat room.\n")
+		return
+	}
+	if room.Access == storage.RoomPublic {
+		c.write(fmt.Sprintf("%s is public; anyone can join.\n", room.Name))
+		return
+	}
+
+	members, err := c.db.GetRoomMembers(room.ID)
+	if err != nil {
+		c.write("Error loading members.\n")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[36mMembers of %s (%s):\033[0m\n", room.Name, room.Access))
+	c.write(c.separator("-", 50) + "\n")
+	if room.OwnerName != "" {
+		c.write(fmt.Sprintf("\033[33m%s\033[0m \033[32m(owner)\033[0m\n", room.OwnerName))
+	}
+	for _, m := range members {
+		how := "with the password"
+		if m.InvitedBy != "" {
+			how = "invited by " + m.InvitedBy
+		}
+		c.write(fmt.Sprintf("\033[33m%s\033[0m \033[90m%s, %s\033[0m\n", m.Username, how, m.CreatedAt.Format("2006-01-02")))
+	}
+	c.write(c.separator("-", 50) + "\n\n")
+}
+
+// setAccess makes a room public, private or password-protected.
+func (c *Client) setAccess(args []string) {
+	usage := fmt.Sprintf("Usage: /access <%s> [room]\n", strings.Join(storage.RoomAccesses, "|"))
+	if len(args) < 1 || len(args) > 2 {
+		c.write(usage)
+		return
+	}
+	access := strings.ToLower(args[0])
+	if access != storage.RoomPublic && access != storage.RoomPrivate && access != storage.RoomPassword {
+		c.write(usage)
+		return
+	}
+	room, ok := c.managedRoom(args, 1)
+	if !ok {
+		return
+	}
+	if room.Name == c.server.config.Seed.DefaultRoom && access != storage.RoomPublic {
+		c.write("The default room has to stay public.\n")
+		return
+	}
+
+	var password string
+	if access == storage.RoomPassword {
+		c.write(fmt.Sprintf("New password for %s: ", room.Name))
+		password, ok = c.readPassword()
+		if !ok || password == "" {
+			c.write("Access not changed.\n")
+			return
+		}
+	}
+
+	if err := c.db.SetRoomAccess(room.ID, access, password); err != nil {
+		c.write("Failed to change access.\n")
+		return
+	}
+	changed := *room
+	changed.Access = access
+	c.replaceRoom(&changed)
+	c.write(fmt.Sprintf("\033[32m%s is now %s.\033[0m\n", room.Name, access))
+	if access != storage.RoomPublic {
+		c.evictLosers(&changed, fmt.Sprintf("%s made %s %s", c.user.Username, room.Name, accessDescriptions[access]))
+	}
+}
+
+// Access levels as told to those they shut out
+var accessDescriptions = map[string]string{
+	storage.RoomPrivate:  "members only",
+	storage.RoomPassword: "password-protected",
+}
+
+// evictLosers moves everyone who may no longer enter a room to the
+// default room; see EvictFromRoom.
+func (c *Client) evictLosers(room *storage.ChatRoom, reason string) {
+	// Reread the room in case it changed since the user looked it up
+	current, err := c.db.GetChatRoom(room.Name)
+	if err != nil {
+		current = room
+	}
+	defaultRoom, err := c.db.GetChatRoom(c.server.config.Seed.DefaultRoom)
+	if err != nil {
+		log.Printf("Failed to move users out of %s: %v", room.Name, err)
+		return
+	}
+	c.server.EvictFromRoom(current, defaultRoom, reason)
+}
+
+// createRoom makes a new public room owned by the user and joins it.
+func (c *Client) createRoom(args []string) {
+	if len(args) < 2 || strings.ToLower(args[0]) != "room" {
+		c.write("Usage: /create room <name> [description]\n")
+		return
+	}
+	if !c.hasRole(c.server.config.Roles.CreateRooms) {
+		c.write("You don't have permission to create rooms.\n")
+		return
+	}
+	name, description := args[1], strings.Join(args[2:], " ")
+	if !storage.ValidRoomName(name) {
+		c.write(fmt.Sprintf("Room names are 2 to %d letters, digits, '-' or '_'.\n", storage.MaxRoomNameLength))
+		return
+	}
+
+	// Names differing only in case would be too easy to confuse
+	if room, err := c.db.FindChatRoom(name); err == nil {
+		c.write(fmt.Sprintf("There's already a room called %s.\n", room.Name))
+		return
+	} else if err != sql.ErrNoRows {
+		c.write("Failed to create room.\n")
+		return
+	}
+
+	switch err := c.db.CreateOwnedRoom(name, description, c.user.ID); err {
+	case nil:
+	case storage.ErrRoomExists:
+		c.write(fmt.Sprintf("There's already a room called %s.\n", name))
+		return
+	default:
+		c.write("Failed to create room.\n")
+		return
+	}
+	log.Printf("%s created room %s", c.user.Username, name)
+	c.write(fmt.Sprintf("\033[32mCreated room %s. It's yours to manage; see 'help'.\033[0m\n", name))
+	c.joinRoom(name)
+}
+
+const roomUsage = "Usage: /room describe <text> | transfer <user> [room] | archive [room] | unarchive [room] | delete <room>\n"
+
+// manageRoom runs the "room" subcommands for room owners.
+func (c *Client) manageRoom(args []string) {
+	if len(args) == 0 {
+		c.write(roomUsage)
+		return
+	}
+	subcommand, args := strings.ToLower(args[0]), args[1:]
+
+	switch subcommand {
+	case "describe":
+		c.describeRoom(strings.Join(args, " "))
+	case "transfer":
+		c.transferRoom(args)
+	case "archive", "unarchive":
+		c.archiveRoom(args, subcommand == "archive")
+	case "delete":
+		c.deleteRoom(args)
+	default:
+		c.write(roomUsage)
+	}
+}
+
+// describeRoom changes the current room's description.
+func (c *Client) describeRoom(description string) {
+	if description == "" {
+		c.write("Usage: /room describe <text>\n")
+		return
+	}
+	room, ok := c.managedRoom(nil, 0)
+	if !ok {
+		return
+	}
+	if err := c.db.SetRoomDescription(room.ID, description); err != nil {
+		c.write("Failed to change the description.\n")
+		return
+	}
+	changed := *room
+	changed.Description = description
+	c.replaceRoom(&changed)
+	c.write(fmt.Sprintf("\033[32mUpdated the description of %s.\033[0m\n", room.Name))
+}
+
+// transferRoom hands a room to another user. The previous owner stays a
+// member so a private room doesn't lock them out.
+func (c *Client) transferRoom(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		c.write("Usage: /room transfer <user> [room]\n")
+		return
+	}
+	room, ok := c.managedRoom(args, 1)
+	if !ok {
+		return
+	}
+	user, err := c.db.GetUserByName(args[0])
+	if err != nil {
+		c.write(fmt.Sprintf("No such user '%s'.\n", args[0]))
+		return
+	}
+	if user.ID == room.OwnerID {
+		c.write(fmt.Sprintf("%s already owns %s.\n", user.Username, room.Name))
+		return
+	}
+
+	if err := c.db.SetRoomOwner(room.ID, user.ID); err != nil {
+		c.write("Failed to transfer the room.\n")
+		return
+	}
+	if room.OwnerID != 0 {
+		c.db.AddRoomMember(room.ID, room.OwnerID, user.ID)
+	}
+	changed := *room
+	changed.OwnerID, changed.OwnerName = user.ID, user.Username
+	c.replaceRoom(&changed)
+	log.Printf("%s gave room %s to %s", c.user.Username, room.Name, user.Username)
+	c.write(fmt.Sprintf("\033[32m%s now owns %s.\033[0m\n", user.Username, room.Name))
+	c.server.SendToUser(user.ID, fmt.Sprintf("\033[32m*** %s gave you the room %s ***\033[0m\n", c.user.Username, room.Name))
+}
+
+// archiveRoom makes a room read-only, or writable again.
+func (c *Client) archiveRoom(args []string, archive bool) {
+	if len(args) > 1 {
+		c.write("Usage: /room archive|unarchive [room]\n")
+		return
+	}
+	room, ok := c.managedRoom(args, 0)
+	if !ok {
+		return
+	}
+	if archive && room.Name == c.server.config.Seed.DefaultRoom {
+		c.write("The default room can't be archived.\n")
+		return
+	}
+	if archive && !room.ArchivedAt.IsZero() {
+		c.write(fmt.Sprintf("%s is already archived.\n", room.Name))
+		return
+	}
+	if !archive && room.ArchivedAt.IsZero() {
+		c.write(fmt.Sprintf("%s isn't archived.\n", room.Name))
+		return
+	}
+
+	if err := c.db.ArchiveRoom(room.ID, archive); err != nil {
+		c.write("Failed to change the room.\n")
+		return
+	}
+	changed := *room
+	notice := fmt.Sprintf("*** %s reopened %s ***", c.user.Username, room.Name)
+	changed.ArchivedAt = time.Time{}
+	if archive {
+		notice = fmt.Sprintf("*** %s archived %s; it's read-only now ***", c.user.Username, room.Name)
+		changed.ArchivedAt = time.Now()
+	}
+	c.replaceRoom(&changed)
+	c.write(fmt.Sprintf("\033[32m%s\033[0m\n", notice))
+	c.server.BroadcastToRoom(room.ID, fmt.Sprintf("\033[36m%s\033[0m\n", notice), c)
+}
+
+// deleteRoom removes a room and its history for good, after the owner
+// types its name again. Anyone inside is moved to the default room.
+func (c *Client) deleteRoom(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /room delete <room>\n")
+		return
+	}
+	room, ok := c.managedRoom(args, 0)
+	if !ok {
+		return
+	}
+	if room.Name == c.server.config.Seed.DefaultRoom {
+		c.write("The default room can't be deleted.\n")
+		return
+	}
+	defaultRoom, err := c.db.GetChatRoom(c.server.config.Seed.DefaultRoom)
+	if err != nil {
+		c.write("Failed to delete the room.\n")
+		return
+	}
+
+	c.write(fmt.Sprintf("This deletes %s and all its messages. Type the room's name to confirm: ", room.Name))
+	if !c.scanner.Scan() {
+		return
+	}
+	if strings.TrimSpace(c.scanner.Text()) != room.Name {
+		c.write("Room not deleted.\n")
+		return
+	}
+
+	if err := c.db.DeleteChatRoom(room.ID); err != nil {
+		c.write("Failed to delete the room.\n")
+		return
+	}
+	log.Printf("%s deleted room %s", c.user.Username, room.Name)
+	c.server.MoveRoomClients(room.ID, defaultRoom, fmt.Sprintf("\033[31m*** %s deleted %s; you are back in %s ***\033[0m\n",
+		c.user.Username, room.Name, defaultRoom.Name))
+	c.write(fmt.Sprintf("\033[32mDeleted room %s.\033[0m\n", room.Name))
+}
+
+// MoveRoomClients puts everyone whose current room is roomID into another
+// one and drops it from everyone's subscriptions, e.g. when the room is
+// deleted, and tells them why. Each client makes the move itself before
+// its next command.
+func (s *BBSServer) MoveRoomClients(roomID int, to *storage.ChatRoom, message string) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for client := range s.clients {
+		if heard, _ := client.leaveRoom(roomID, to); heard {
+			client.deliver(message)
+		}
+	}
+}
+
+// EvictFromRoom moves everyone who may no longer enter a room out of it
+// into another, as MoveRoomClients does, e.g. after they're uninvited or
+// the room stops being public. reason starts the notice they're sent.
+func (s *BBSServer) EvictFromRoom(room, to *storage.ChatRoom, reason string) {
+	allowed := make(map[int]bool) // by user ID
+	for _, client := range s.GetClientsInRoom(room.ID) {
+		ok, checked := allowed[client.user.ID]
+		if !checked {
+			ok = s.mayEnter(room, client.user)
+			allowed[client.user.ID] = ok
+		}
+		if ok {
+			continue
+		}
+
+		heard, moved := client.leaveRoom(room.ID, to)
+		switch {
+		case moved:
+			client.deliver(fmt.Sprintf("\033[31m*** %s; you are back in %s ***\033[0m\n", reason, to.Name))
+		case heard:
+			client.deliver(fmt.Sprintf("\033[31m*** %s; you are no longer subscribed to it ***\033[0m\n", reason))
+		}
+	}
+}
+
+// mayEnter is canEnter for any user, reading their role from the database.
+func (s *BBSServer) mayEnter(room *storage.ChatRoom, user *storage.User) bool {
+	if room.Access == storage.RoomPublic || room.OwnerID == user.ID {
+		return true
+	}
+	if member, err := s.db.IsRoomMember(room.ID, user.ID); err == nil && member {
+		return true
+	}
+	current, err := s.db.GetUserByName(user.Username)
+	return err == nil && current.Role == storage.RoleSysop
+}
+
+// sweepIdleRooms archives idle rooms every roomSweepInterval until ctx is
+// cancelled.
+func (s *BBSServer) sweepIdleRooms(ctx context.Context) {
+	ticker := time.NewTicker(roomSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		idle := time.Duration(s.config.Limits.RoomIdleDays) * 24 * time.Hour
+		s.archiveIdleRooms(time.Now().Add(-idle))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveIdleRooms archives the user rooms nobody has written in since
+// before and nobody is in, and reports how many there were.
+func (s *BBSServer) archiveIdleRooms(before time.Time) int {
+	rooms, err := s.db.GetIdleRooms(before)
+	if err != nil {
+		log.Printf("Failed to look for idle rooms: %v", err)
+		return 0
+	}
+
+	archived := 0
+	for _, room := range rooms {
+		if len(s.GetClientsInRoom(room.ID)) > 0 {
+			continue
+		}
+		if err := s.db.ArchiveRoom(room.ID, true); err != nil {
+			log.Printf("Failed to archive idle room %s: %v", room.Name, err)
+			continue
+		}
+		log.Printf("Archived idle room %s", room.Name)
+		archived++
+	}
+	return archived
+}
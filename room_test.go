@@ -0,0 +1,272 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+// privateRoom creates a members-only room owned by owner.
+func (s *testServer) privateRoom(name, owner string) {
+	s.t.Helper()
+
+	user, err := s.db.GetUserByName(owner)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.db.CreateChatRoom(name, owner+"'s room")
+	room, _ := s.db.GetChatRoom(name)
+	if err := s.db.SetRoomOwner(room.ID, user.ID); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := s.db.SetRoomAccess(room.ID, storage.RoomPrivate, ""); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func TestPrivateRoom(t *testing.T) {
+	s := startTestServer(t, nil)
+	s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	dave := s.register("dave", "secret")
+	s.privateRoom("Den", "bob")
+
+	carol.send("rooms")
+	if out := carol.expectPrompt(); strings.Contains(out, "Den") {
+		t.Errorf("outsider sees the private room: %q", out)
+	}
+	carol.send("join Den")
+	carol.expect("Room 'Den' not found.")
+
+	bob.send("join Den")
+	bob.expect("Joined room: Den")
+	bob.send("secret plans")
+	dave.send("/search plans")
+	dave.expect("No messages found.")
+	dave.send("/search in:Den plans")
+	dave.expect("Room 'Den' not found.")
+
+	bob.send("/invite carol")
+	bob.expect("carol can now join Den.")
+	carol.expect("*** bob invited you to Den; type 'join Den' ***")
+	carol.send("join Den")
+	carol.expect("Joined room: Den")
+	carol.expect("secret plans")
+	carol.send("/invite dave")
+	carol.expect("You don't own Den.")
+
+	bob.send("/members")
+	out := bob.expect("carol invited by bob")
+	if !strings.Contains(out, "Members of Den (private)") || !strings.Contains(out, "bob (owner)") {
+		t.Errorf("members: %q", out)
+	}
+
+	bob.send("/uninvite carol Den")
+	bob.expect("carol is no longer a member of Den.")
+	carol.expect("*** bob removed you from Den ***")
+	carol.expect("*** You can no longer enter Den; you are back in General ***")
+	bob.send("after removal secret")
+	carol.expectNothing("after removal secret")
+	carol.send("still here?")
+	carol.expect("[General]> ")
+	bob.expectNothing("still here?")
+}
+
+func TestAccessChangeMovesOutsiders(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	dave := s.register("dave", "secret")
+	s.privateRoom("Den", "bob")
+	den, _ := s.db.GetChatRoom("Den")
+	s.db.SetRoomAccess(den.ID, storage.RoomPublic, "")
+
+	for _, c := range []*testClient{alice, bob, carol} {
+		c.send("join Den")
+		c.expect("Joined room: Den")
+	}
+	dave.send("/subscribe Den")
+	dave.expect("Subscribed to Den.")
+
+	bob.send("/access private")
+	bob.expect("Den is now private.")
+	carol.expect("*** bob made Den members only; you are back in General ***")
+	dave.expect("*** bob made Den members only; you are no longer subscribed to it ***")
+
+	// The owner and the sysop stay
+	bob.send("just us now")
+	alice.expect("bob: just us now")
+	carol.expectNothing("just us now")
+	dave.expectNothing("just us now")
+}
+
+func TestPasswordRoom(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	s.privateRoom("Den", "bob")
+
+	bob.send("/access private General")
+	bob.expect("You don't own General.")
+	alice.send("/access private General")
+	alice.expect("The default room has to stay public.")
+
+	bob.send("/access password Den")
+	bob.expect("New password for Den:")
+	bob.send("sesame")
+	bob.expect("Den is now password.")
+
+	carol.send("join Den")
+	carol.expect("Password for Den:")
+	carol.send("open up")
+	carol.expect("Wrong password.")
+	carol.send("join Den")
+	carol.expect("Password for Den:")
+	carol.send("sesame")
+	carol.expect("Joined room: Den")
+
+	// Once in, the room is listed and the password isn't asked again
+	carol.expectPrompt()
+	carol.send("rooms")
+	if out := carol.expectPrompt(); !strings.Contains(out, "Den - bob's room [password] (current)") {
+		t.Errorf("rooms: %q", out)
+	}
+	carol.send("join General")
+	carol.expect("Joined room: General")
+	carol.send("join Den")
+	carol.expect("Joined room: Den")
+}
+
+func TestCreateRoom(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+
+	bob.send("/create room Den Bob's den")
+	bob.expect("Created room Den.")
+	bob.expect("Joined room: Den")
+	carol.send("/create room den")
+	carol.expect("There's already a room called Den.")
+	carol.send("/create room x")
+	carol.expect("Room names are 2 to 30 letters")
+
+	carol.send("join Den")
+	carol.expect("Joined room: Den")
+	carol.send("/room describe Mine now")
+	carol.expect("You don't own Den.")
+	bob.send("/room describe Cosy corner")
+	bob.expect("Updated the description of Den.")
+	bob.send("first post")
+	carol.expect("bob: first post")
+
+	// Naming the room updates bob's copy of his current one too
+	bob.send("/room archive Den")
+	bob.expect("*** bob archived Den; it's read-only now ***")
+	carol.expect("*** bob archived Den; it's read-only now ***")
+	carol.send("anyone?")
+	carol.expect("Den is archived and read-only.")
+	bob.send("/edit last rewritten history")
+	bob.expect("Den is archived and read-only.")
+	bob.send("/delete last")
+	bob.expect("Den is archived and read-only.")
+	carol.expectNothing("rewritten history")
+	carol.expectNothing("deleted their message")
+	carol.expectPrompt()
+	carol.send("rooms")
+	if out := carol.expectPrompt(); !strings.Contains(out, "Den - Cosy corner [archived] (current)") {
+		t.Errorf("rooms: %q", out)
+	}
+	bob.send("/room unarchive")
+	carol.expect("*** bob reopened Den ***")
+
+	bob.send("/room transfer carol")
+	bob.expect("carol now owns Den.")
+	carol.expect("*** bob gave you the room Den ***")
+	bob.send("/room describe Still mine")
+	bob.expect("You don't own Den.")
+
+	carol.send("/room delete Den")
+	carol.expect("Type the room's name to confirm:")
+	carol.send("nope")
+	carol.expect("Room not deleted.")
+	carol.send("/room delete Den")
+	carol.expect("Type the room's name to confirm:")
+	carol.send("Den")
+	bob.expect("*** carol deleted Den; you are back in General ***")
+	carol.expect("Deleted room Den.")
+	alice.send("welcome back")
+	bob.expect("alice: welcome back")
+	bob.send("back again")
+	alice.expect("bob: back again")
+}
+
+func TestCreateRoomPermission(t *testing.T) {
+	s := startTestServer(t, func(config *Config) {
+		config.Roles.CreateRooms = storage.RoleModerator
+	})
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	bob.send("/create room Den")
+	bob.expect("You don't have permission to create rooms.")
+	alice.send("/create room Den")
+	alice.expect("Created room Den.")
+}
+
+func TestIdleRoomsArchived(t *testing.T) {
+	s := startTestServer(t, nil)
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+
+	bob.send("/create room Den")
+	bob.expect("Joined room: Den")
+	bob.send("join General")
+	bob.expect("Joined room: General")
+	carol.send("/create room Nook")
+	carol.expect("Joined room: Nook")
+
+	// Everything counts as idle with a cutoff in the future, but Nook
+	// is occupied and General has no owner
+	if n := s.archiveIdleRooms(time.Now().Add(time.Minute)); n != 1 {
+		t.Errorf("archived %d rooms, want 1", n)
+	}
+	if den, _ := s.db.GetChatRoom("Den"); den.ArchivedAt.IsZero() {
+		t.Error("Den not archived")
+	}
+	if nook, _ := s.db.GetChatRoom("Nook"); !nook.ArchivedAt.IsZero() {
+		t.Error("occupied room archived")
+	}
+}
+
+func TestLostRoomHidesHistory(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	s.privateRoom("Den", "bob")
+
+	bob.send("join Den")
+	bob.expect("Joined room: Den")
+	bob.send("secret plans")
+
+	// A sysop demoted behind their back is no longer let in
+	user, _ := s.db.GetUserByName("alice")
+	for _, command := range []string{"history", "pins", "topic history"} {
+		s.db.SetUserRole(user.ID, storage.RoleSysop)
+		alice.send("join Den")
+		alice.expect("Joined room: Den")
+		alice.expectPrompt()
+		s.db.SetUserRole(user.ID, storage.RoleUser)
+
+		alice.send(command)
+		alice.expect("You can no longer enter Den.")
+		if out := alice.expectPrompt(); strings.Contains(out, "secret plans") {
+			t.Errorf("%s after losing access: %q", command, out)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"bbs/internal/storage"
+)
+
+// Most search results listed at once
+const searchLimit = 20
+
+// Columns of context kept around the first match in a result
+const snippetWidth = 60
+
+// How search matches are highlighted: inverse bold
+const (
+	highlightStart = "\033[1;7m"
+	highlightEnd   = "\033[0m"
+)
+
+const searchUsage = "Usage: /search [in:<room>] [by:<user>] <words or \"a phrase\">\n"
+
+// searchMessages finds chat messages containing every term, newest first.
+func (c *Client) searchMessages(input string) {
+	var q storage.SearchQuery
+	for _, token := range splitQuoted(input) {
+		lower := strings.ToLower(token)
+		switch {
+		case strings.HasPrefix(lower, "in:") && len(token) > 3:
+			room, err := c.db.GetChatRoom(token[3:])
+			if err != nil || !c.canEnter(room) {
+				c.write(fmt.Sprintf("Room '%s' not found.\n", token[3:]))
+				return
+			}
+			q.RoomID = room.ID
+		case strings.HasPrefix(lower, "by:") && len(token) > 3:
+			user, err := c.db.GetUserByName(token[3:])
+			if err != nil {
+				c.write(fmt.Sprintf("No such user '%s'.\n", token[3:]))
+				return
+			}
+			q.UserID = user.ID
+		default:
+			q.Terms = append(q.Terms, token)
+		}
+	}
+	if len(q.Terms) == 0 {
+		c.write(searchUsage)
+		return
+	}
+	if !c.hasRole(storage.RoleSysop) {
+		q.ViewerID = c.user.ID
+	}
+	q.Highlight = [2]string{highlightStart, highlightEnd}
+
+	results, err := c.db.SearchMessages(q, searchLimit+1)
+	if err != nil {
+		c.write("Search failed.\n")
+		return
+	}
+	if len(results) == 0 {
+		c.write("No messages found.\n")
+		return
+	}
+	more := len(results) > searchLimit
+	if more {
+		results = results[:searchLimit]
+	}
+
+	c.write(fmt.Sprintf("\033[36mMessages matching %s:\033[0m\n", formatTerms(q.Terms)))
+	c.write(c.separator("-", 60) + "\n")
+	for _, r := range results {
+		// Without FTS5 the snippet is cut out here instead
+		snippet := r.Snippet
+		if snippet == "" {
+			snippet = highlightSnippet(r.Content, q.Terms)
+		}
+		c.writeWrapped(fmt.Sprintf("\033[90m[%s]\033[0m \033[36m%s\033[0m \033[33m%s:\033[0m %s%s\n",
+			r.Timestamp.Format("2006-01-02 15:04"), r.RoomName, r.Username, snippet, editedMarker(r.Message)))
+	}
+	c.write(c.separator("-", 60) + "\n")
+	if more {
+		c.write(fmt.Sprintf("Showing the newest %d matches. Add words, in:<room> or by:<user> to narrow the search.\n", searchLimit))
+	}
+	c.write("\n")
+}
+
+// splitQuoted splits s into words, keeping "quoted phrases" together
+// without their quotes.
+func splitQuoted(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			flush()
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func formatTerms(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// highlightSnippet cuts content down to about snippetWidth columns around
+// the first search word it contains and highlights every search word.
+func highlightSnippet(content string, terms []string) string {
+	text := []rune(strings.Join(strings.Fields(content), " "))
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var words [][]rune
+	for _, term := range terms {
+		for _, word := range strings.Fields(term) {
+			words = append(words, []rune(strings.ToLower(word)))
+		}
+	}
+
+	// Mark every rune that is part of a search word
+	marked := make([]bool, len(text))
+	first := -1
+	for _, word := range words {
+		for i := 0; i+len(word) <= len(lower); i++ {
+			if string(lower[i:i+len(word)]) != string(word) {
+				continue
+			}
+			for j := i; j < i+len(word); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start, end := 0, len(text)
+	if len(text) > snippetWidth {
+		if first > snippetWidth/3 {
+			start = first - snippetWidth/3
+		}
+		if start+snippetWidth < end {
+			end = start + snippetWidth
+		} else {
+			start = end - snippetWidth
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString(highlightStart)
+		}
+		b.WriteRune(text[i])
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString(highlightEnd)
+		}
+	}
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	alice.send("msg where did we put the release checklist?")
+	alice.expectPrompt()
+	bob.send("join Tech")
+	bob.expect("[Tech]> ")
+	bob.send("msg the release is tagged, checklist done")
+	bob.expectPrompt()
+
+	alice.send("/search checklist")
+	out := alice.expectPrompt()
+	if !strings.Contains(out, "General alice: where did we put the release checklist?") ||
+		!strings.Contains(out, "Tech bob: the release is tagged, checklist done") {
+		t.Errorf("search checklist: %q", out)
+	}
+
+	alice.send(`/search "release checklist"`)
+	out = alice.expectPrompt()
+	if !strings.Contains(out, "alice: where did") || strings.Contains(out, "bob:") {
+		t.Errorf("phrase search: %q", out)
+	}
+
+	alice.send("/search in:Tech release")
+	if out := alice.expectPrompt(); strings.Contains(out, "alice:") || !strings.Contains(out, "bob:") {
+		t.Errorf("room filter: %q", out)
+	}
+	alice.send("/search by:alice release")
+	if out := alice.expectPrompt(); !strings.Contains(out, "alice:") || strings.Contains(out, "bob:") {
+		t.Errorf("author filter: %q", out)
+	}
+
+	alice.send("/search zebra")
+	alice.expect("No messages found.")
+	alice.send("/search in:Nowhere release")
+	alice.expect("Room 'Nowhere' not found.")
+	alice.send("/search by:alice")
+	alice.expect("Usage: /search")
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	got := highlightSnippet("Is the Release ready?", []string{"release"})
+	if want := "Is the \033[1;7mRelease\033[0m ready?"; got != want {
+		t.Errorf("short message: %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("lorem ", 30) + "needle " + strings.Repeat("ipsum ", 30)
+	got = highlightSnippet(long, []string{"needle"})
+	if !strings.HasPrefix(got, "...") || !strings.HasSuffix(got, "...") || !strings.Contains(got, "\033[1;7mneedle\033[0m") {
+		t.Errorf("long message: %q", got)
+	}
+	if n := visibleLen(got); n > snippetWidth+6 {
+		t.Errorf("snippet is %d columns", n)
+	}
+}
@@ -2,84 +2,261 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
+
+	"bbs/internal/storage"
 )
 
+// How long a refused connection gets to receive the reason
+const refuseTimeout = 5 * time.Second
+
 type BBSServer struct {
-	db      *Database
+	db      storage.Store
+	config  *Config
 	clients map[*Client]bool
 	mutex   sync.RWMutex
+
+	// Open connections on all front-ends, for limits.max_connections
+	connections int
+	connMutex   sync.Mutex
+
+	// Bound address of each front-end, by its listen.* config name
+	addrs     map[string]net.Addr
+	addrMutex sync.Mutex
+	ready     chan struct{}
+
+	queues queueMetrics
 }
 
-func NewBBSServer(db *Database) *BBSServer {
+func NewBBSServer(db storage.Store, config *Config) *BBSServer {
 	return &BBSServer{
 		db:      db,
+		config:  config,
 		clients: make(map[*Client]bool),
+		addrs:   make(map[string]net.Addr),
+		ready:   make(chan struct{}),
 	}
 }
 
-func (s *BBSServer) Start(port string) error {
-	listener, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return fmt.Errorf("failed to start server: %v", err)
-	}
-	defer listener.Close()
+// Start runs every listener enabled in the config until SIGINT/SIGTERM.
+func (s *BBSServer) Start() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.Serve(ctx)
+}
 
-	log.Printf("BBS Server started on port %s", port)
+// Ready is closed once every listener started by Serve is accepting.
+func (s *BBSServer) Ready() <-chan struct{} {
+	return s.ready
+}
 
-	// Handle graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// Addr returns the address a front-end ("telnet", "tls", "ssh" or "web")
+// is listening on, or nil if it isn't running. Useful with port 0.
+func (s *BBSServer) Addr(name string) net.Addr {
+	s.addrMutex.Lock()
+	defer s.addrMutex.Unlock()
+	return s.addrs[name]
+}
 
-	// Listen for interrupt signals
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+func (s *BBSServer) setAddr(name string, addr net.Addr) {
+	s.addrMutex.Lock()
+	defer s.addrMutex.Unlock()
+	s.addrs[name] = addr
+}
 
-	go func() {
-		<-signalChan
-		log.Println("Shutdown signal received, closing server...")
-		cancel()
-		listener.Close()
+// Serve runs every listener enabled in the config until ctx is cancelled.
+func (s *BBSServer) Serve(ctx context.Context) error {
+	listen := s.config.Listen
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
 	}()
 
-	// Accept connections
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	handleTelnet := func(conn net.Conn) {
+		client := NewClient(conn, s.db, s)
+		client.Handle()
+	}
+
+	if listen.Telnet != "" {
+		listener, err := s.listen(ctx, listen.Telnet)
+		if err != nil {
+			return fmt.Errorf("failed to start server: %v", err)
+		}
+		listeners = append(listeners, listener)
+		s.setAddr("telnet", listener.Addr())
+
+		log.Printf("BBS Server started on %s", listener.Addr())
+		go s.acceptLoop(ctx, listener, handleTelnet)
+	}
+
+	if listen.TLS != "" {
+		config, err := loadOrCreateTLSConfig(s.config.TLS.CertFile, s.config.TLS.KeyFile)
+		if err != nil {
+			return err
+		}
+		tcpListener, err := s.listen(ctx, listen.TLS)
+		if err != nil {
+			return fmt.Errorf("failed to start TLS server: %v", err)
+		}
+		tlsListener := tls.NewListener(tcpListener, config)
+		listeners = append(listeners, tlsListener)
+		s.setAddr("tls", tlsListener.Addr())
+
+		log.Printf("TLS telnet server started on %s", tlsListener.Addr())
+		go s.acceptLoop(ctx, tlsListener, func(conn net.Conn) {
+			if s.finishTLSHandshake(conn) {
+				handleTelnet(conn)
+			}
+		})
+	}
+
+	if listen.SSH != "" {
+		config, err := s.newSSHConfig(s.config.SSH.HostKey)
+		if err != nil {
+			return err
+		}
+		sshListener, err := s.listen(ctx, listen.SSH)
+		if err != nil {
+			return fmt.Errorf("failed to start SSH server: %v", err)
+		}
+		listeners = append(listeners, sshListener)
+		s.setAddr("ssh", sshListener.Addr())
+
+		log.Printf("SSH server started on %s", sshListener.Addr())
+		go s.acceptLoop(ctx, sshListener, func(conn net.Conn) {
+			s.handleSSHConn(conn, config)
+		})
+	}
+
+	if listen.Web != "" {
+		webListener, err := s.listen(ctx, listen.Web)
+		if err != nil {
+			return fmt.Errorf("failed to start web server: %v", err)
+		}
+		listeners = append(listeners, webListener)
+		s.setAddr("web", webListener.Addr())
+
+		log.Printf("Web terminal started on http://%s/", webListener.Addr())
+		// Connections that never finish sending a request don't hold on
+		// past the login timeout
+		server := &http.Server{Handler: s.newWebHandler(), ReadHeaderTimeout: s.config.Timeouts.Login}
+		go func() {
+			if err := server.Serve(webListener); err != nil && ctx.Err() == nil {
+				log.Printf("Web server error: %v", err)
+			}
+		}()
+	}
+
+	if len(listeners) == 0 {
+		return fmt.Errorf("no listeners enabled")
+	}
+
+	if s.config.Limits.RoomIdleDays > 0 {
+		go s.sweepIdleRooms(ctx)
+	}
+
+	close(s.ready)
+	<-ctx.Done()
+
+	log.Println("Shutdown signal received, closing server...")
+	cancel()
+	for _, l := range listeners {
+		l.Close()
+	}
+	listeners = nil
+
+	log.Println("Server shutdown completed")
+	return nil
+}
+
+// listen opens a TCP listener whose connections send keepalive probes
+// every timeouts.keepalive, so dead peers are noticed even when idle.
+func (s *BBSServer) listen(ctx context.Context, addr string) (net.Listener, error) {
+	config := net.ListenConfig{KeepAlive: s.config.Timeouts.Keepalive}
+	if config.KeepAlive == 0 {
+		config.KeepAlive = -1 // off, rather than Go's default
+	}
+	return config.Listen(ctx, "tcp", addr)
+}
+
+// acceptLoop hands every connection accepted on listener to handle in its
+// own goroutine until ctx is cancelled.
+func (s *BBSServer) acceptLoop(ctx context.Context, listener net.Listener, handle func(net.Conn)) {
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Server shutdown completed")
-			return nil
-		default:
-			conn, err := listener.Accept()
-			if err != nil {
-				if ctx.Err() != nil {
-					return nil // Server is shutting down
-				}
-				log.Printf("Error accepting connection: %v", err)
-				continue
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return // Server is shutting down
 			}
+			log.Printf("Error accepting connection: %v", err)
+			continue
+		}
 
-			// Handle client in a new goroutine
-			go func() {
-				client := NewClient(conn, s.db, s)
-				client.Handle()
-			}()
+		if !s.acquireConnection() {
+			go refuseConnection(conn, "Sorry, the BBS is full. Please try again later.")
+			continue
 		}
+
+		// Handle client in a new goroutine
+		go func() {
+			defer s.releaseConnection()
+			if ban := s.addrBan(conn.RemoteAddr()); ban != nil {
+				refuseConnection(conn, banNotice(ban))
+				return
+			}
+			handle(conn)
+		}()
+	}
+}
+
+// refuseConnection tells a peer why it is being turned away and hangs up,
+// giving up on the notice if the peer doesn't take it in time.
+func refuseConnection(conn net.Conn, notice string) {
+	conn.SetWriteDeadline(time.Now().Add(refuseTimeout))
+	conn.Write([]byte(notice + "\r\n"))
+	conn.Close()
+}
+
+// acquireConnection reserves a slot under limits.max_connections.
+func (s *BBSServer) acquireConnection() bool {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	if max := s.config.Limits.MaxConnections; max > 0 && s.connections >= max {
+		return false
 	}
+	s.connections++
+	return true
+}
+
+func (s *BBSServer) releaseConnection() {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	s.connections--
 }
 
 func (s *BBSServer) AddClient(client *Client) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	
+
 	s.clients[client] = true
 	log.Printf("User %s connected. Total online: %d", client.user.Username, len(s.clients))
-	
+
 	// Notify other users in the same room
 	if client.currentRoom != nil {
 		message := fmt.Sprintf("\033[90m*** %s joined the room ***\033[0m\n", client.user.Username)
@@ -90,11 +267,11 @@ func (s *BBSServer) AddClient(client *Client) {
 func (s *BBSServer) RemoveClient(client *Client) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	
+
 	if _, exists := s.clients[client]; exists {
 		delete(s.clients, client)
 		log.Printf("User %s disconnected. Total online: %d", client.user.Username, len(s.clients))
-		
+
 		// Notify other users in the same room
 		if client.currentRoom != nil {
 			message := fmt.Sprintf("\033[90m*** %s left the room ***\033[0m\n", client.user.Username)
@@ -103,39 +280,122 @@ func (s *BBSServer) RemoveClient(client *Client) {
 	}
 }
 
+// BroadcastToRoom writes message to everyone but sender who is in the
+// room or subscribed to it. Subscribers see it marked with the room name.
 func (s *BBSServer) BroadcastToRoom(roomID int, message string, sender *Client) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	
+	s.broadcastToRoomExcluding(roomID, message, sender)
+}
+
+// broadcastToRoomExcluding expects the caller to hold s.mutex. It must not
+// take the lock itself: AddClient and RemoveClient call it while holding
+// the write lock, and RLock there would deadlock the server.
+func (s *BBSServer) broadcastToRoomExcluding(roomID int, message string, excludeClient *Client) {
 	for client := range s.clients {
-		if client != sender && client.currentRoom != nil && client.currentRoom.ID == roomID {
-			client.write(message)
+		if client == excludeClient {
+			continue
+		}
+		if label, ok := client.roomLabel(roomID); ok {
+			client.deliver(label + message)
 		}
 	}
 }
 
-func (s *BBSServer) broadcastToRoomExcluding(roomID int, message string, excludeClient *Client) {
+// SendToUser writes message to every session of a user and reports how
+// many there were.
+func (s *BBSServer) SendToUser(userID int, message string) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sent := 0
+	for client := range s.clients {
+		if client.user != nil && client.user.ID == userID {
+			client.deliver(message)
+			sent++
+		}
+	}
+	return sent
+}
+
+// DisconnectUser writes message to every session of a user, closes them
+// and reports how many there were.
+func (s *BBSServer) DisconnectUser(userID int, message string) int {
+	return s.disconnect(func(client *Client) bool {
+		return client.user != nil && client.user.ID == userID
+	}, message)
+}
+
+// DisconnectAddr does the same for every session from an IP address.
+func (s *BBSServer) DisconnectAddr(ip string, message string) int {
+	return s.disconnect(func(client *Client) bool {
+		return client.remoteIP() == ip
+	}, message)
+}
+
+// disconnect closes the connections of matching clients once message has
+// been sent. Their Handle loops see the closed connection and remove them
+// from the map.
+func (s *BBSServer) disconnect(match func(*Client) bool, message string) int {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	
+
+	closed := 0
+	for client := range s.clients {
+		if match(client) {
+			client.deliver(message)
+			client.hangUpAfter()
+			closed++
+		}
+	}
+	return closed
+}
+
+// UserAddrs returns the IP addresses a user is connected from.
+func (s *BBSServer) UserAddrs(userID int) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var addrs []string
+	seen := make(map[string]bool)
 	for client := range s.clients {
-		if client != excludeClient && client.currentRoom != nil && client.currentRoom.ID == roomID {
-			client.write(message)
+		if client.user != nil && client.user.ID == userID {
+			if ip := client.remoteIP(); ip != "" && !seen[ip] {
+				seen[ip] = true
+				addrs = append(addrs, ip)
+			}
 		}
 	}
+	return addrs
+}
+
+// AddrUsers returns the names of the users connected from an IP address.
+func (s *BBSServer) AddrUsers(ip string) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var users []string
+	seen := make(map[string]bool)
+	for client := range s.clients {
+		if client.user != nil && client.remoteIP() == ip && !seen[client.user.Username] {
+			seen[client.user.Username] = true
+			users = append(users, client.user.Username)
+		}
+	}
+	return users
 }
 
 func (s *BBSServer) GetOnlineUsers() []string {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	
+
 	var users []string
 	for client := range s.clients {
 		if client.user != nil {
 			users = append(users, client.user.Username)
 		}
 	}
-	
+
 	return users
 }
 
@@ -148,22 +408,22 @@ func (s *BBSServer) GetClientCount() int {
 func (s *BBSServer) BroadcastGlobal(message string) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	
+
 	for client := range s.clients {
-		client.write(message)
+		client.deliver(message)
 	}
 }
 
 func (s *BBSServer) GetClientsInRoom(roomID int) []*Client {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	
+
 	var clients []*Client
 	for client := range s.clients {
-		if client.currentRoom != nil && client.currentRoom.ID == roomID {
+		if client.inRoom(roomID) {
 			clients = append(clients, client)
 		}
 	}
-	
+
 	return clients
-}
\ No newline at end of file
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Permissions extension carrying the fingerprint of the key a user
+// authenticated with
+const sshKeyExtension = "bbs-key-fingerprint"
+
+// loadOrCreateHostKey reads the SSH host key from path, generating and
+// saving a new ed25519 key on first start.
+func loadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(private, "bbs host key")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("Generated new SSH host key %s", path)
+
+	return ssh.NewSignerFromKey(private)
+}
+
+func (s *BBSServer) newSSHConfig(hostKeyPath string) (*ssh.ServerConfig, error) {
+	hostKey, err := loadOrCreateHostKey(hostKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH host key: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		// Everyone else gets in without questions and goes through the
+		// normal BBS login/registration prompts
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			return &ssh.Permissions{}, nil
+		},
+		ServerVersion: "SSH-2.0-EnhancedBBS",
+	}
+	config.AddHostKey(hostKey)
+
+	// Keys registered with the "keys" command log straight in
+	if s.config.Features.SSHKeyLogin {
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if _, err := s.db.GetUserByKey(fingerprint); err != nil {
+				return nil, errors.New("unknown key")
+			}
+			return &ssh.Permissions{Extensions: map[string]string{sshKeyExtension: fingerprint}}, nil
+		}
+	}
+
+	return config, nil
+}
+
+func (s *BBSServer) handleSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	// The handshake and asking for a shell count towards the login
+	// timeout; the deadline is lifted once the BBS session starts
+	if login := s.config.Timeouts.Login; login > 0 {
+		conn.SetDeadline(time.Now().Add(login))
+	}
+
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		log.Printf("SSH handshake with %s failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	// The connection holds one slot under limits.max_connections, so it
+	// gets one BBS session
+	opened := false
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		if opened {
+			newChannel.Reject(ssh.Prohibited, "only one session per connection")
+			continue
+		}
+		opened = true
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			log.Printf("Error accepting SSH channel: %v", err)
+			continue
+		}
+		go s.handleSSHSession(sshConn, conn, channel, channelRequests)
+	}
+}
+
+// handleSSHSession runs one SSH "session" channel as a BBS client once the
+// peer asks for a shell.
+func (s *BBSServer) handleSSHSession(sshConn *ssh.ServerConn, conn net.Conn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	term := newSSHTerminal(channel, conn)
+	client := NewTerminalClient(term, s.db, s)
+
+	if fingerprint := sshConn.Permissions.Extensions[sshKeyExtension]; fingerprint != "" {
+		if user, err := s.db.GetUserByKey(fingerprint); err == nil {
+			client.user = user
+		}
+	}
+
+	started := false
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			if width, height, ok := parsePtyRequest(req.Payload); ok {
+				client.setWindowSize(width, height)
+			}
+			term.setPty(true)
+			req.Reply(true, nil)
+		case "window-change":
+			if width, height, ok := parseWindowChange(req.Payload); ok {
+				client.setWindowSize(width, height)
+			}
+		case "shell":
+			if started {
+				req.Reply(false, nil)
+				continue
+			}
+			started = true
+			req.Reply(true, nil)
+			// From here the client's own login and idle timeouts apply
+			conn.SetDeadline(time.Time{})
+			go func() {
+				client.Handle()
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				channel.Close()
+			}()
+		case "env":
+			req.Reply(true, nil)
+		default:
+			// exec, subsystem, x11 and friends make no sense for a BBS
+			req.Reply(false, nil)
+		}
+	}
+
+	if !started {
+		channel.Close()
+	}
+}
+
+// parsePtyRequest extracts the terminal size from a "pty-req" payload:
+// string TERM, uint32 columns, uint32 rows, uint32 width px, uint32 height
+// px, string modes.
+func parsePtyRequest(payload []byte) (int, int, bool) {
+	if len(payload) < 4 {
+		return 0, 0, false
+	}
+	termLen := int(binary.BigEndian.Uint32(payload))
+	if len(payload) < 4+termLen+8 {
+		return 0, 0, false
+	}
+	return parseWindowChange(payload[4+termLen:])
+}
+
+// parseWindowChange extracts columns and rows from a "window-change"
+// payload.
+func parseWindowChange(payload []byte) (int, int, bool) {
+	if len(payload) < 8 {
+		return 0, 0, false
+	}
+	width := int(binary.BigEndian.Uint32(payload[0:4]))
+	height := int(binary.BigEndian.Uint32(payload[4:8]))
+	return width, height, true
+}
+
+// sshTerminal adapts an SSH session channel to the Terminal interface.
+// When the peer allocated a PTY its terminal is in raw mode, so the
+// terminal does line editing and echo itself, the way a tty driver would.
+type sshTerminal struct {
+	channel ssh.Channel
+	conn    net.Conn
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	pty        bool
+	echo       bool
+
+	line   []byte // line being edited
+	ready  []byte // completed lines waiting to be read
+	escape bool   // inside an ANSI escape sequence sent by a cursor key
+	lastCR bool
+	input  *deadlineReader
+
+	lastOut byte // last byte written by Write, guarded by writeMutex
+}
+
+func newSSHTerminal(channel ssh.Channel, conn net.Conn) *sshTerminal {
+	return &sshTerminal{
+		channel: channel,
+		conn:    conn,
+		echo:    true,
+		input: newDeadlineReader(func() readResult {
+			buf := make([]byte, 1024)
+			n, err := channel.Read(buf)
+			return readResult{data: buf[:n], err: err}
+		}),
+	}
+}
+
+func (t *sshTerminal) setPty(pty bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pty = pty
+}
+
+func (t *sshTerminal) SuppressEcho(suppress bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.echo = !suppress
+}
+
+func (t *sshTerminal) Read(p []byte) (int, error) {
+	for len(t.ready) == 0 {
+		result := t.input.next()
+		if editErr := t.edit(result.data); editErr != nil {
+			return 0, editErr
+		}
+		if result.err != nil && len(t.ready) == 0 {
+			return 0, result.err
+		}
+	}
+
+	n := copy(p, t.ready)
+	t.ready = t.ready[n:]
+	return n, nil
+}
+
+// edit applies minimal line discipline to raw input: echo, backspace,
+// ^U to kill the line, ^C to abandon it and ^D on an empty line for EOF.
+func (t *sshTerminal) edit(data []byte) error {
+	t.mutex.Lock()
+	pty, echo := t.pty, t.echo
+	t.mutex.Unlock()
+
+	if !pty {
+		// The peer's own terminal is cooked, just normalise line endings
+		for _, b := range data {
+			if t.lastCR {
+				t.lastCR = false
+				if b == '\n' {
+					continue
+				}
+			}
+			if b == '\r' {
+				t.lastCR = true
+				b = '\n'
+			}
+			t.ready = append(t.ready, b)
+		}
+		return nil
+	}
+
+	var out []byte
+	for _, b := range data {
+		if t.escape {
+			// Skip ESC [ ... final byte
+			if b >= 0x40 && b <= 0x7e && b != '[' {
+				t.escape = false
+			}
+			continue
+		}
+
+		switch {
+		case b == '\r' || b == '\n':
+			if b == '\n' && t.lastCR {
+				t.lastCR = false
+				continue
+			}
+			t.lastCR = b == '\r'
+			t.ready = append(t.ready, t.line...)
+			t.ready = append(t.ready, '\n')
+			t.line = t.line[:0]
+			out = append(out, '\r', '\n')
+			continue
+		case b == 0x7f || b == 0x08:
+			if len(t.line) > 0 {
+				_, size := utf8.DecodeLastRune(t.line)
+				t.line = t.line[:len(t.line)-size]
+				if echo {
+					out = append(out, '\b', ' ', '\b')
+				}
+			}
+		case b == 0x15: // ^U
+			if echo {
+				for i := utf8.RuneCount(t.line); i > 0; i-- {
+					out = append(out, '\b', ' ', '\b')
+				}
+			}
+			t.line = t.line[:0]
+		case b == 0x03: // ^C
+			t.line = t.line[:0]
+			out = append(out, '^', 'C', '\r', '\n')
+			t.ready = append(t.ready, '\n')
+		case b == 0x04: // ^D
+			if len(t.line) == 0 {
+				t.writeRaw(out)
+				return io.EOF
+			}
+		case b == 0x1b:
+			t.escape = true
+		case b < 0x20:
+			// Ignore other control characters
+		default:
+			t.line = append(t.line, b)
+			if echo {
+				out = append(out, b)
+			}
+		}
+		t.lastCR = false
+	}
+
+	if len(out) > 0 {
+		t.writeRaw(out)
+	}
+	return nil
+}
+
+func (t *sshTerminal) writeRaw(data []byte) error {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+	_, err := t.channel.Write(data)
+	return err
+}
+
+// Write converts "\n" to "\r\n" since a raw-mode PTY won't. A "\r"
+// ending one write still pairs with a "\n" starting the next.
+func (t *sshTerminal) Write(p []byte) (int, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	out := make([]byte, 0, len(p)+len(p)/8)
+	prev := t.lastOut
+	for _, b := range p {
+		if b == '\n' && prev != '\r' {
+			out = append(out, '\r')
+		}
+		out = append(out, b)
+		prev = b
+	}
+	t.lastOut = prev
+
+	if _, err := t.channel.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *sshTerminal) Close() error {
+	t.input.close()
+	return t.channel.Close()
+}
+
+func (t *sshTerminal) LocalAddr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+func (t *sshTerminal) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+// Read deadlines apply to this channel only, write deadlines to the whole
+// SSH connection underneath it
+func (t *sshTerminal) SetDeadline(deadline time.Time) error {
+	t.input.setDeadline(deadline)
+	return t.conn.SetWriteDeadline(deadline)
+}
+
+func (t *sshTerminal) SetReadDeadline(deadline time.Time) error {
+	t.input.setDeadline(deadline)
+	return nil
+}
+
+func (t *sshTerminal) SetWriteDeadline(deadline time.Time) error {
+	return t.conn.SetWriteDeadline(deadline)
+}
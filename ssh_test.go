@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startSSHServer starts a test server that also listens for SSH, with a
+// host key of its own.
+func startSSHServer(t *testing.T) *testServer {
+	t.Helper()
+	return startTestServer(t, func(config *Config) {
+		config.Listen.SSH = "127.0.0.1:0"
+		config.SSH.HostKey = filepath.Join(t.TempDir(), "ssh_host_ed25519_key")
+		config.Features.SSHKeyLogin = true
+	})
+}
+
+// dialSSH opens an SSH connection to the server, authenticating with auth.
+func (s *testServer) dialSSH(auth ssh.AuthMethod) (*ssh.Client, error) {
+	client, err := ssh.Dial("tcp", s.Addr("ssh").String(), &ssh.ClientConfig{
+		User:            "guest",
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         testTimeout,
+	})
+	if err == nil {
+		s.t.Cleanup(func() { client.Close() })
+	}
+	return client, err
+}
+
+// sshShell starts a shell on a width x height PTY. The session is bridged
+// onto a net.Pipe so the usual testClient, with its deadlines, can drive
+// it.
+func (s *testServer) sshShell(client *ssh.Client, width, height int) (*testClient, *ssh.Session) {
+	s.t.Helper()
+
+	session, err := client.NewSession()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if err := session.RequestPty("xterm", height, width, ssh.TerminalModes{}); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := session.Shell(); err != nil {
+		s.t.Fatal(err)
+	}
+
+	local, remote := net.Pipe()
+	s.t.Cleanup(func() { local.Close() })
+	go func() {
+		io.Copy(remote, stdout)
+		remote.Close()
+	}()
+	go io.Copy(stdin, remote)
+
+	return &testClient{t: s.t, conn: local, telnet: NewTelnetConn(local)}, session
+}
+
+// keyboardInteractive answers whatever the server asks with nothing,
+// which is all the BBS asks before its own login prompts.
+var keyboardInteractive = ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	return make([]string, len(questions)), nil
+})
+
+func TestSSHKeyboardInteractive(t *testing.T) {
+	s := startSSHServer(t)
+	s.register("alice", "secret")
+
+	client, err := s.dialSSH(keyboardInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, session := s.sshShell(client, 30, 20)
+	c.expect("(L)ogin or (R)egister?")
+	c.send("l")
+	c.expect("Username:")
+	c.send("alice")
+	out := c.expect("Password:")
+	c.send("secret")
+	out += c.expect("Welcome back, alice")
+	c.expectPrompt()
+	if strings.Contains(out, "secret") {
+		t.Errorf("password echoed: %q", out)
+	}
+
+	// The PTY's width sizes the output, and so does a later resize
+	c.send("rooms")
+	out = c.expectPrompt()
+	if !strings.Contains(out, strings.Repeat("-", 30)+"\n") || strings.Contains(out, strings.Repeat("-", 31)) {
+		t.Errorf("rooms at 30 columns: %q", out)
+	}
+	if err := session.WindowChange(20, 40); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(testTimeout)
+	for !strings.Contains(out, strings.Repeat("-", 40)+"\n") {
+		if time.Now().After(deadline) {
+			t.Fatalf("rooms after resizing to 40 columns: %q", out)
+		}
+		c.send("rooms")
+		out = c.expectPrompt()
+	}
+
+	c.send("quit")
+	c.expect("Goodbye!")
+	c.expectClosed()
+}
+
+func TestSSHKeyLogin(t *testing.T) {
+	s := startSSHServer(t)
+	alice := s.register("alice", "secret")
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.send("/keys add " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))) + " laptop")
+	alice.expect("Added SSH key " + ssh.FingerprintSHA256(signer.PublicKey()))
+
+	// An unknown key is refused outright
+	_, stranger, _ := ed25519.GenerateKey(rand.Reader)
+	strangerSigner, _ := ssh.NewSignerFromKey(stranger)
+	if _, err := s.dialSSH(ssh.PublicKeys(strangerSigner)); err == nil {
+		t.Error("unknown key accepted")
+	}
+
+	// last_seen is kept in whole seconds, so log in in a later one
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	loginAt := time.Now().Truncate(time.Second)
+
+	client, err := s.dialSSH(ssh.PublicKeys(signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := s.sshShell(client, 80, 24)
+	out := c.expect("Welcome back, alice!")
+	c.expectPrompt()
+	if strings.Contains(out, "(L)ogin or (R)egister?") {
+		t.Errorf("asked to log in with a registered key: %q", out)
+	}
+
+	user, err := s.db.GetUserByName("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.LastSeen.Unix() < loginAt.Unix() {
+		t.Errorf("last_seen %v is from before the key login at %v", user.LastSeen, loginAt)
+	}
+
+	c.send("hello from ssh")
+	alice.expect("alice: hello from ssh")
+}
+
+func TestSSHOneSessionPerConnection(t *testing.T) {
+	s := startSSHServer(t)
+	s.register("alice", "secret")
+
+	client, err := s.dialSSH(keyboardInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := s.sshShell(client, 80, 24)
+	c.expect("(L)ogin or (R)egister?")
+
+	// A second session would be a second BBS client on one connection
+	// slot, getting around limits.max_connections
+	if session, err := client.NewSession(); err == nil {
+		session.Close()
+		t.Fatal("second session on one connection accepted")
+	}
+	c.send("l")
+	c.expect("Username:")
+}
+
+// recordingChannel is an ssh.Channel that keeps what is written to it.
+type recordingChannel struct {
+	ssh.Channel
+	sent bytes.Buffer
+}
+
+func (c *recordingChannel) Write(p []byte) (int, error) { return c.sent.Write(p) }
+
+func TestSSHWriteSplitCRLF(t *testing.T) {
+	channel := &recordingChannel{}
+	term := newSSHTerminal(channel, nil)
+
+	for _, chunk := range []string{"a\r", "\nb\n"} {
+		if _, err := term.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := channel.sent.String(), "a\r\nb\r\n"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
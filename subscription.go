@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"bbs/internal/storage"
+)
+
+// Most rooms followed in the background at once
+const maxSubscriptions = 10
+
+// inRoom reports whether the client hears what's said in a room: it's
+// their current room or one they subscribed to.
+func (c *Client) inRoom(roomID int) bool {
+	_, ok := c.roomLabel(roomID)
+	return ok
+}
+
+// roomLabel returns the prefix for a message from a room the client hears,
+// "" for their current room, and whether they hear it at all.
+func (c *Client) roomLabel(roomID int) (string, bool) {
+	c.roomMutex.RLock()
+	defer c.roomMutex.RUnlock()
+
+	if current := c.focusedRoom(); current != nil && current.ID == roomID {
+		return "", true
+	}
+	if room, ok := c.subscriptions[roomID]; ok {
+		return fmt.Sprintf("\033[36m[%s]\033[0m ", room.Name), true
+	}
+	return "", false
+}
+
+// setCurrentRoom changes the room plain text goes to, overriding any move
+// another goroutine left for the client.
+func (c *Client) setCurrentRoom(room *storage.ChatRoom) {
+	c.roomMutex.Lock()
+	defer c.roomMutex.Unlock()
+	c.currentRoom, c.movedTo = room, nil
+}
+
+// replaceRoom swaps an updated copy of a room in for the client's own,
+// wherever it holds one, e.g. after changing its description. Only the
+// client's own goroutine calls it; see applyRoomChanges.
+func (c *Client) replaceRoom(room *storage.ChatRoom) {
+	c.roomMutex.Lock()
+	defer c.roomMutex.Unlock()
+
+	if c.currentRoom != nil && c.currentRoom.ID == room.ID {
+		c.currentRoom = room
+	}
+	if c.movedTo != nil && c.movedTo.ID == room.ID {
+		c.movedTo = room
+	}
+	if _, ok := c.subscriptions[room.ID]; ok {
+		c.subscriptions[room.ID] = room
+	}
+}
+
+// focusedRoom returns the room plain text goes to once pending changes are
+// applied. The caller must hold roomMutex.
+func (c *Client) focusedRoom() *storage.ChatRoom {
+	if c.movedTo != nil {
+		return c.movedTo
+	}
+	return c.currentRoom
+}
+
+// applyRoomChanges makes the changes other goroutines left for the
+// client, such as a move out of a deleted room or a new topic. Only the
+// client's own goroutine calls it, between commands. Rooms are replaced
+// rather than changed, as other goroutines may still hold the old ones.
+func (c *Client) applyRoomChanges() {
+	c.roomMutex.Lock()
+	defer c.roomMutex.Unlock()
+
+	if c.movedTo != nil {
+		c.currentRoom, c.movedTo = c.movedTo, nil
+	}
+	for roomID, change := range c.topicChanges {
+		if c.currentRoom != nil && c.currentRoom.ID == roomID {
+			c.currentRoom = withTopic(c.currentRoom, change)
+		}
+		if room, ok := c.subscriptions[roomID]; ok {
+			c.subscriptions[roomID] = withTopic(room, change)
+		}
+	}
+	c.topicChanges = nil
+}
+
+// subscribed reports whether the client follows a room in the background.
+func (c *Client) subscribed(roomID int) bool {
+	c.roomMutex.RLock()
+	defer c.roomMutex.RUnlock()
+	_, ok := c.subscriptions[roomID]
+	return ok
+}
+
+// subscribedRooms returns the rooms followed in the background by name.
+func (c *Client) subscribedRooms() []*storage.ChatRoom {
+	c.roomMutex.RLock()
+	defer c.roomMutex.RUnlock()
+
+	rooms := make([]*storage.ChatRoom, 0, len(c.subscriptions))
+	for _, room := range c.subscriptions {
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms
+}
+
+// dropSubscription stops following a room and reports whether the client
+// was following it.
+func (c *Client) dropSubscription(roomID int) bool {
+	c.roomMutex.Lock()
+	defer c.roomMutex.Unlock()
+
+	if _, ok := c.subscriptions[roomID]; !ok {
+		return false
+	}
+	delete(c.subscriptions, roomID)
+	return true
+}
+
+// leaveRoom stops the client hearing a room: it drops the subscription
+// and, if the room is the focused one, leaves a move to another room for
+// the client's own goroutine. It reports whether the client heard the room
+// and whether it will be moved.
+func (c *Client) leaveRoom(roomID int, to *storage.ChatRoom) (heard, moved bool) {
+	c.roomMutex.Lock()
+	defer c.roomMutex.Unlock()
+
+	_, heard = c.subscriptions[roomID]
+	delete(c.subscriptions, roomID)
+	if current := c.focusedRoom(); current != nil && current.ID == roomID {
+		room := *to
+		c.movedTo = &room
+		return true, true
+	}
+	return heard, false
+}
+
+// markSubscriptionsRead records that the user has seen everything in the
+// rooms they follow; messages there were shown as they came in.
+func (c *Client) markSubscriptionsRead() {
+	for _, room := range c.subscribedRooms() {
+		c.db.MarkRoomRead(c.user.ID, room.ID)
+	}
+}
+
+// subscribe follows a room in the background, so its messages show up
+// whichever room is current. Without args it lists the rooms followed.
+func (c *Client) subscribe(args []string) {
+	if len(args) == 0 {
+		c.listSubscriptions()
+		return
+	}
+	if len(args) != 1 {
+		c.write("Usage: /subscribe [room]\n")
+		return
+	}
+
+	room, ok := c.enterableRoom(args[0])
+	if !ok {
+		return
+	}
+	if c.subscribed(room.ID) {
+		c.write(fmt.Sprintf("You are already subscribed to %s.\n", room.Name))
+		return
+	}
+	if len(c.subscribedRooms()) >= maxSubscriptions {
+		c.write(fmt.Sprintf("You can follow at most %d rooms; unsubscribe from one first.\n", maxSubscriptions))
+		return
+	}
+
+	c.roomMutex.Lock()
+	c.subscriptions[room.ID] = room
+	c.roomMutex.Unlock()
+
+	if c.currentRoom != nil && c.currentRoom.ID == room.ID {
+		c.write(fmt.Sprintf("\033[32mSubscribed to %s; you'll keep hearing it after joining another room.\033[0m\n", room.Name))
+		return
+	}
+	// Messages from here on arrive live, so only earlier ones are unread
+	c.db.MarkRoomRead(c.user.ID, room.ID)
+	c.write(fmt.Sprintf("\033[32mSubscribed to %s. Its messages will be shown marked [%s].\033[0m\n", room.Name, room.Name))
+}
+
+// unsubscribe stops following a room in the background.
+func (c *Client) unsubscribe(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /unsubscribe <room>\n")
+		return
+	}
+
+	var room *storage.ChatRoom
+	for _, r := range c.subscribedRooms() {
+		if strings.EqualFold(r.Name, args[0]) {
+			room = r
+		}
+	}
+	if room == nil || !c.dropSubscription(room.ID) {
+		c.write(fmt.Sprintf("You aren't subscribed to %s.\n", args[0]))
+		return
+	}
+
+	c.db.MarkRoomRead(c.user.ID, room.ID)
+	if c.currentRoom != nil && c.currentRoom.ID == room.ID {
+		c.write(fmt.Sprintf("\033[32mUnsubscribed from %s; you'll leave it when you join another room.\033[0m\n", room.Name))
+		return
+	}
+	c.write(fmt.Sprintf("\033[32mUnsubscribed from %s.\033[0m\n", room.Name))
+}
+
+func (c *Client) listSubscriptions() {
+	rooms := c.subscribedRooms()
+	if len(rooms) == 0 {
+		c.write("You aren't subscribed to any rooms. Type '/subscribe <room>' to follow one alongside this one.\n")
+		return
+	}
+
+	c.write("\033[36mSubscribed rooms:\033[0m\n")
+	for _, room := range rooms {
+		marker := ""
+		if c.currentRoom != nil && room.ID == c.currentRoom.ID {
+			marker = " \033[32m(current)\033[0m"
+		}
+		c.write(fmt.Sprintf("  \033[33m%s\033[0m%s\n", room.Name, marker))
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestSubscriptions(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	bob.send("join Tech")
+	bob.expect("Joined room: Tech")
+
+	alice.send("/subscribe Tech")
+	alice.expect("Subscribed to Tech. Its messages will be shown marked [Tech].")
+	alice.send("/subscribe Tech")
+	alice.expect("You are already subscribed to Tech.")
+
+	bob.send("anyone around?")
+	alice.expect("[Tech]")
+	alice.expect("bob: anyone around?")
+
+	// Plain text still goes to the focused room
+	alice.send("hello general")
+	bob.expectNothing("hello general")
+
+	alice.send("join Tech")
+	alice.expect("Now talking in: Tech")
+	alice.expect("[Tech]> ")
+	alice.send("hi bob")
+	bob.expect("alice: hi bob")
+
+	alice.send("/unsubscribe Tech")
+	alice.expect("Unsubscribed from Tech; you'll leave it when you join another room.")
+	alice.send("join General")
+	alice.expect("Joined room: General")
+	alice.send("/subscribe")
+	alice.expect("You aren't subscribed to any rooms.")
+	bob.send("still there?")
+	alice.expectNothing("still there?")
+}
+
+func TestSubscribePrivateRoom(t *testing.T) {
+	s := startTestServer(t, nil)
+	s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	s.privateRoom("Lounge", "bob")
+	bob.send("join Lounge")
+	bob.expect("Joined room: Lounge")
+
+	carol.send("/subscribe Lounge")
+	carol.expect("Room 'Lounge' not found.")
+
+	bob.send("/invite carol")
+	bob.expect("carol can now join Lounge.")
+	carol.send("/subscribe Lounge")
+	carol.expect("Subscribed to Lounge.")
+	bob.send("welcome")
+	carol.expect("bob: welcome")
+
+	bob.send("/uninvite carol")
+	bob.expect("carol is no longer a member of Lounge.")
+	bob.send("goodbye")
+	carol.expectNothing("goodbye")
+	carol.send("/sub")
+	carol.expect("You aren't subscribed to any rooms.")
+}
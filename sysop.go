@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bbs/internal/storage"
+)
+
+// setRole changes another user's role and tells them if they're online.
+func (c *Client) setRole(args []string) {
+	if len(args) != 2 {
+		c.write(fmt.Sprintf("Usage: /role <user> <%s>\n", strings.Join(storage.Roles, "|")))
+		return
+	}
+	role := strings.ToLower(args[1])
+	if storage.RoleRank(role) < 0 {
+		c.write(fmt.Sprintf("Unknown role '%s'. Roles are: %s.\n", args[1], strings.Join(storage.Roles, ", ")))
+		return
+	}
+
+	user, err := c.db.GetUserByName(args[0])
+	if err != nil {
+		c.write(fmt.Sprintf("No such user '%s'.\n", args[0]))
+		return
+	}
+	if user.ID == c.user.ID {
+		c.write("You can't change your own role.\n")
+		return
+	}
+	if user.Role == role {
+		c.write(fmt.Sprintf("%s is already a %s.\n", user.Username, role))
+		return
+	}
+
+	if err := c.db.SetUserRole(user.ID, role); err != nil {
+		c.write("Failed to change role.\n")
+		return
+	}
+	c.write(fmt.Sprintf("\033[32m%s is now a %s.\033[0m\n", user.Username, role))
+	c.server.SendToUser(user.ID, fmt.Sprintf("\033[32m*** %s made you a %s ***\033[0m\n", c.user.Username, role))
+}
+
+// listAccounts shows every registered account with its role.
+func (c *Client) listAccounts() {
+	users, err := c.db.ListUsers()
+	if err != nil {
+		c.write("Error loading users.\n")
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[36mRegistered Users (%d):\033[0m\n", len(users)))
+	c.write(c.separator("-", 60) + "\n")
+	for _, user := range users {
+		c.write(fmt.Sprintf("\033[33m%-20s\033[0m %-10s joined %s, last seen %s\n", user.Username, user.Role,
+			user.JoinedAt.Format("2006-01-02"), user.LastSeen.Format("2006-01-02 15:04")))
+	}
+	c.write(c.separator("-", 60) + "\n\n")
+}
+
+// broadcast sends a notice to everyone online.
+func (c *Client) broadcast(text string) {
+	if text == "" {
+		c.write("Usage: /broadcast <message>\n")
+		return
+	}
+	c.server.BroadcastGlobal(fmt.Sprintf("\033[31m*** SYSOP %s: %s ***\033[0m\n", c.user.Username, text))
+}
+
+// setMOTD replaces the message of the day.
+func (c *Client) setMOTD() {
+	c.write("New message of the day.\n")
+	text, ok := c.readText(maxTextLines)
+	if !ok {
+		return
+	}
+	if err := c.db.SetMOTD(text, c.user.Username); err != nil {
+		c.write("Failed to update MOTD.\n")
+		return
+	}
+	c.write("\033[32mMOTD updated.\033[0m\n")
+}
+
+// showRevisions shows what a message said before each edit or deletion.
+func (c *Client) showRevisions(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /revisions <message id>\n")
+		return
+	}
+	messageID, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		c.write("Usage: /revisions <message id>\n")
+		return
+	}
+
+	// Messages in rooms the user can't enter look like ones never changed
+	var revisions []storage.Revision
+	room, err := c.db.GetMessageRoom(messageID)
+	if err == nil && c.canEnter(room) {
+		revisions, err = c.db.GetRevisions(messageID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		c.write("Error loading revisions.\n")
+		return
+	}
+	if len(revisions) == 0 {
+		c.write(fmt.Sprintf("Message %d has never been edited or deleted.\n", messageID))
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[36mRevisions of message %d:\033[0m\n", messageID))
+	c.write(c.separator("-", 60) + "\n")
+	for _, r := range revisions {
+		c.writeWrapped(fmt.Sprintf("\033[90m[%s] before %s:\033[0m %s\n", r.CreatedAt.Format("2006-01-02 15:04"), r.Action, r.Content))
+	}
+	msg, err := c.db.GetMessage(messageID)
+	switch {
+	case err == nil:
+		c.writeWrapped(fmt.Sprintf("\033[90mnow:\033[0m \033[33m%s:\033[0m %s\n", msg.Username, msg.Content))
+	case err == sql.ErrNoRows:
+		c.write("\033[90mnow:\033[0m deleted\n")
+	}
+	c.write(c.separator("-", 60) + "\n\n")
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+)
+
+// Telnet protocol bytes (RFC 854)
+const (
+	telnetSE   = 240
+	telnetNOP  = 241
+	telnetAYT  = 246
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+)
+
+// Telnet options understood by the BBS
+const (
+	TelnetOptEcho = 1  // RFC 857
+	TelnetOptSGA  = 3  // RFC 858
+	TelnetOptNAWS = 31 // RFC 1073
+)
+
+// Options we are willing to perform ourselves (answer DO with WILL)
+var telnetLocalOptions = map[byte]bool{
+	TelnetOptEcho: true,
+	TelnetOptSGA:  true,
+}
+
+// Options we are willing to let the peer perform (answer WILL with DO)
+var telnetRemoteOptions = map[byte]bool{
+	TelnetOptSGA:  true,
+	TelnetOptNAWS: true,
+}
+
+// RFC 1143 ("Q method") states of an option on our side
+const (
+	telnetQNo      = iota
+	telnetQYes     // enabled and agreed to by the peer
+	telnetQWantNo  // sent WONT, awaiting DONT
+	telnetQWantYes // sent WILL, awaiting DO
+)
+
+// telnetQOption is the negotiation state of one of our options. opposite
+// records that the other setting was asked for while a request was still
+// unanswered; it is sent once the peer answers.
+type telnetQOption struct {
+	state    int
+	opposite bool
+}
+
+// Decoder states for the incoming byte stream
+const (
+	telnetStateData = iota
+	telnetStateIAC
+	telnetStateVerb
+	telnetStateSB
+	telnetStateSBIAC
+)
+
+// TelnetConn wraps a net.Conn and speaks the telnet protocol on it. Reads
+// return only user data with IAC sequences stripped and line endings
+// normalised to "\n"; option negotiation is answered automatically.
+// Writes escape IAC bytes and translate "\n" into the NVT "\r\n".
+type TelnetConn struct {
+	net.Conn
+
+	writeMutex    sync.Mutex
+	optMutex      sync.Mutex
+	local         map[byte]telnetQOption // options on our side
+	remote        map[byte]bool          // options enabled on the peer's side
+	pendingRemote map[byte]bool          // remote options we requested and await an answer for
+
+	// OnResize is called with the peer's window size whenever it reports
+	// one via NAWS. It runs on the reading goroutine.
+	OnResize func(width, height int)
+
+	state  int
+	verb   byte
+	sb     []byte
+	lastCR bool
+	buf    []byte
+
+	lastOut byte // last data byte written, guarded by writeMutex
+}
+
+func NewTelnetConn(conn net.Conn) *TelnetConn {
+	return &TelnetConn{
+		Conn:          conn,
+		local:         make(map[byte]telnetQOption),
+		remote:        make(map[byte]bool),
+		pendingRemote: make(map[byte]bool),
+	}
+}
+
+// Read returns decoded user data. It never returns 0 bytes with a nil error.
+func (t *TelnetConn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if cap(t.buf) < len(p) {
+		t.buf = make([]byte, len(p))
+	}
+
+	for {
+		n, err := t.Conn.Read(t.buf[:len(p)])
+		out := t.decode(t.buf[:n], p)
+		if out > 0 || err != nil {
+			return out, err
+		}
+	}
+}
+
+// decode runs the protocol state machine over in, writing data bytes to out.
+// Output is never longer than input, so out may be the same size as in.
+func (t *TelnetConn) decode(in, out []byte) int {
+	n := 0
+	for _, b := range in {
+		switch t.state {
+		case telnetStateData:
+			if b == telnetIAC {
+				t.state = telnetStateIAC
+				continue
+			}
+			// CR LF, CR NUL and a bare CR all end a line
+			if t.lastCR {
+				t.lastCR = false
+				if b == '\n' || b == 0 {
+					continue
+				}
+			}
+			if b == '\r' {
+				t.lastCR = true
+				b = '\n'
+			}
+			out[n] = b
+			n++
+		case telnetStateIAC:
+			t.state = telnetStateData
+			switch b {
+			case telnetIAC:
+				out[n] = b
+				n++
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				t.verb = b
+				t.state = telnetStateVerb
+			case telnetSB:
+				t.sb = t.sb[:0]
+				t.state = telnetStateSB
+			case telnetAYT:
+				t.writeRaw([]byte("\r\n[Yes]\r\n"))
+			}
+		case telnetStateVerb:
+			t.state = telnetStateData
+			t.negotiate(t.verb, b)
+		case telnetStateSB:
+			if b == telnetIAC {
+				t.state = telnetStateSBIAC
+			} else if len(t.sb) < 256 {
+				t.sb = append(t.sb, b)
+			}
+		case telnetStateSBIAC:
+			switch b {
+			case telnetSE:
+				t.state = telnetStateData
+				t.subnegotiate(t.sb)
+			case telnetIAC:
+				t.state = telnetStateSB
+				if len(t.sb) < 256 {
+					t.sb = append(t.sb, b)
+				}
+			default:
+				// Malformed subnegotiation, drop it
+				t.state = telnetStateData
+			}
+		}
+	}
+	return n
+}
+
+// negotiate answers a WILL/WONT/DO/DONT from the peer following the
+// "don't acknowledge a mode you are already in" rule to avoid loops. Our
+// own options follow the RFC 1143 Q method, so a late answer to a request
+// that has since been withdrawn can't switch the option back on.
+func (t *TelnetConn) negotiate(verb, opt byte) {
+	t.optMutex.Lock()
+	defer t.optMutex.Unlock()
+
+	switch verb {
+	case telnetDO:
+		q := t.local[opt]
+		switch q.state {
+		case telnetQNo:
+			if !telnetLocalOptions[opt] {
+				t.sendCommand(telnetWONT, opt)
+				return
+			}
+			q.state = telnetQYes
+			t.sendCommand(telnetWILL, opt)
+		case telnetQWantNo:
+			// DO answering a WONT; the peer has to accept it anyway
+			q = telnetQOption{state: telnetQNo}
+		case telnetQWantYes:
+			if q.opposite {
+				q = telnetQOption{state: telnetQWantNo}
+				t.sendCommand(telnetWONT, opt)
+			} else {
+				q.state = telnetQYes
+			}
+		}
+		t.local[opt] = q
+	case telnetDONT:
+		q := t.local[opt]
+		switch q.state {
+		case telnetQYes:
+			q.state = telnetQNo
+			t.sendCommand(telnetWONT, opt)
+		case telnetQWantNo:
+			if q.opposite {
+				q = telnetQOption{state: telnetQWantYes}
+				t.sendCommand(telnetWILL, opt)
+			} else {
+				q.state = telnetQNo
+			}
+		case telnetQWantYes:
+			// The peer refused
+			q = telnetQOption{state: telnetQNo}
+		}
+		t.local[opt] = q
+	case telnetWILL:
+		if t.pendingRemote[opt] {
+			delete(t.pendingRemote, opt)
+			t.remote[opt] = true
+			return
+		}
+		if !telnetRemoteOptions[opt] {
+			t.sendCommand(telnetDONT, opt)
+			return
+		}
+		if !t.remote[opt] {
+			t.remote[opt] = true
+			t.sendCommand(telnetDO, opt)
+		}
+	case telnetWONT:
+		if t.pendingRemote[opt] {
+			delete(t.pendingRemote, opt)
+			t.remote[opt] = false
+			return
+		}
+		if t.remote[opt] {
+			t.remote[opt] = false
+			t.sendCommand(telnetDONT, opt)
+		}
+	}
+}
+
+// subnegotiate handles a completed IAC SB ... IAC SE block.
+func (t *TelnetConn) subnegotiate(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	switch data[0] {
+	case TelnetOptNAWS:
+		// IAC SB NAWS <width16> <height16> IAC SE
+		if len(data) != 5 {
+			return
+		}
+		width := int(binary.BigEndian.Uint16(data[1:3]))
+		height := int(binary.BigEndian.Uint16(data[3:5]))
+		if t.OnResize != nil {
+			t.OnResize(width, height)
+		}
+	}
+}
+
+// SetLocalOption asks to enable or disable an option on our side, sending
+// WILL or WONT if the state actually changes. The option counts as enabled
+// once the peer answers DO. Asking again while an earlier request is still
+// unanswered queues the new setting until the answer arrives.
+func (t *TelnetConn) SetLocalOption(opt byte, enable bool) {
+	t.optMutex.Lock()
+	defer t.optMutex.Unlock()
+
+	q := t.local[opt]
+	switch q.state {
+	case telnetQNo:
+		if enable {
+			q.state = telnetQWantYes
+			t.sendCommand(telnetWILL, opt)
+		}
+	case telnetQYes:
+		if !enable {
+			q.state = telnetQWantNo
+			t.sendCommand(telnetWONT, opt)
+		}
+	case telnetQWantNo:
+		q.opposite = enable
+	case telnetQWantYes:
+		q.opposite = !enable
+	}
+	t.local[opt] = q
+}
+
+// RequestRemoteOption asks the peer to start performing an option,
+// sending DO if it isn't already enabled. The option counts as enabled
+// once the peer answers WILL; one that never answers hasn't agreed.
+func (t *TelnetConn) RequestRemoteOption(opt byte) {
+	t.optMutex.Lock()
+	defer t.optMutex.Unlock()
+
+	if t.remote[opt] || t.pendingRemote[opt] {
+		return
+	}
+	t.pendingRemote[opt] = true
+	t.sendCommand(telnetDO, opt)
+}
+
+// LocalOption reports whether we have agreed to perform an option.
+func (t *TelnetConn) LocalOption(opt byte) bool {
+	t.optMutex.Lock()
+	defer t.optMutex.Unlock()
+	return t.local[opt].state == telnetQYes
+}
+
+// RemoteOption reports whether the peer has agreed to perform an option.
+func (t *TelnetConn) RemoteOption(opt byte) bool {
+	t.optMutex.Lock()
+	defer t.optMutex.Unlock()
+	return t.remote[opt]
+}
+
+// SuppressEcho tells the peer to stop echoing typed characters locally by
+// claiming the ECHO option for the server (which then echoes nothing).
+func (t *TelnetConn) SuppressEcho(suppress bool) {
+	t.SetLocalOption(TelnetOptEcho, suppress)
+}
+
+// Ping sends a NOP, which the peer ignores but which fails once the
+// connection is gone.
+func (t *TelnetConn) Ping() error {
+	return t.writeRaw([]byte{telnetIAC, telnetNOP})
+}
+
+func (t *TelnetConn) sendCommand(verb, opt byte) {
+	t.writeRaw([]byte{telnetIAC, verb, opt})
+}
+
+func (t *TelnetConn) writeRaw(data []byte) error {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+	_, err := t.Conn.Write(data)
+	return err
+}
+
+// Write escapes IAC bytes and converts bare "\n" into "\r\n". A "\r"
+// ending one write still pairs with a "\n" starting the next.
+func (t *TelnetConn) Write(p []byte) (int, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	out := make([]byte, 0, len(p)+len(p)/8)
+	prev := t.lastOut
+	for _, b := range p {
+		switch {
+		case b == telnetIAC:
+			out = append(out, telnetIAC, telnetIAC)
+		case b == '\n' && prev != '\r':
+			out = append(out, '\r', '\n')
+		default:
+			out = append(out, b)
+		}
+		prev = b
+	}
+	t.lastOut = prev
+
+	if _, err := t.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
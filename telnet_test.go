@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// telnetPipe returns a TelnetConn on one end of a net.Pipe and the raw
+// peer on the other. Everything the TelnetConn sends the peer is
+// collected and handed over on replies when the pipe closes.
+func telnetPipe(t *testing.T) (*TelnetConn, net.Conn, <-chan string) {
+	t.Helper()
+
+	server, peer := net.Pipe()
+	t.Cleanup(func() { server.Close() })
+
+	replies := make(chan string, 1)
+	go func() {
+		var got []byte
+		buf := make([]byte, 256)
+		for {
+			n, err := peer.Read(buf)
+			got = append(got, buf[:n]...)
+			if err != nil {
+				replies <- string(got)
+				return
+			}
+		}
+	}()
+	return NewTelnetConn(server), peer, replies
+}
+
+// readUntil reads decoded data from tc until it ends with marker.
+func readUntil(t *testing.T, tc *TelnetConn, marker string) string {
+	t.Helper()
+
+	tc.SetReadDeadline(time.Now().Add(testTimeout))
+	var got []byte
+	buf := make([]byte, 64)
+	for !strings.HasSuffix(string(got), marker) {
+		n, err := tc.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			t.Fatalf("read %q: %v", got, err)
+		}
+	}
+	return strings.TrimSuffix(string(got), marker)
+}
+
+func TestTelnetDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunks  []string // written by the peer, one read each
+		want    string   // decoded data
+		replies string   // sent back by the TelnetConn
+		resized string   // reported through OnResize
+	}{
+		{
+			name:    "IAC sequence split across reads",
+			chunks:  []string{"ab\xff", "\xfb", "\x03cd"},
+			want:    "abcd",
+			replies: "\xff\xfd\x03", // WILL SGA answered with DO SGA
+		},
+		{
+			name:   "escaped IAC",
+			chunks: []string{"a\xff\xffb", "\xff", "\xffc"},
+			want:   "a\xffb\xffc",
+		},
+		{
+			name:   "line endings",
+			chunks: []string{"one\r\x00two\r\n", "three\r", "\nfour\rfive"},
+			want:   "one\ntwo\nthree\nfour\nfive",
+		},
+		{
+			name:    "DO and DONT acknowledged once",
+			chunks:  []string{"\xff\xfd\x01", "\xff\xfd\x01", "\xff\xfe\x01", "\xff\xfe\x01x"},
+			want:    "x",
+			replies: "\xff\xfb\x01\xff\xfc\x01", // WILL ECHO, WONT ECHO
+		},
+		{
+			name:    "unsupported options refused",
+			chunks:  []string{"\xff\xfd\x18\xff\xfb\x18x"},
+			want:    "x",
+			replies: "\xff\xfc\x18\xff\xfe\x18", // WONT TTYPE, DONT TTYPE
+		},
+		{
+			name:    "NAWS with an escaped 255",
+			chunks:  []string{"\xff\xfa\x1f\x00\xff", "\xff\x00\x18\xff\xf0x"},
+			want:    "x",
+			resized: "255x24",
+		},
+		{
+			name:   "malformed subnegotiation dropped",
+			chunks: []string{"\xff\xfa\x1f\x00\x50\xff\x01x"},
+			want:   "x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, peer, replies := telnetPipe(t)
+			var resized string
+			tc.OnResize = func(width, height int) {
+				resized = fmt.Sprintf("%dx%d", width, height)
+			}
+
+			go func() {
+				for _, chunk := range append(tt.chunks, "$") {
+					if _, err := peer.Write([]byte(chunk)); err != nil {
+						return
+					}
+				}
+			}()
+			if got := readUntil(t, tc, "$"); got != tt.want {
+				t.Errorf("data = %q, want %q", got, tt.want)
+			}
+			tc.Close()
+			if got := <-replies; got != tt.replies {
+				t.Errorf("replies = %q, want %q", got, tt.replies)
+			}
+			if resized != tt.resized {
+				t.Errorf("resized = %q, want %q", resized, tt.resized)
+			}
+		})
+	}
+}
+
+func TestTelnetRemoteOption(t *testing.T) {
+	tc, peer, replies := telnetPipe(t)
+
+	tc.RequestRemoteOption(TelnetOptNAWS)
+	if tc.RemoteOption(TelnetOptNAWS) {
+		t.Error("NAWS enabled before the peer agreed")
+	}
+
+	go peer.Write([]byte("\xff\xfb\x1f$"))
+	readUntil(t, tc, "$")
+	if !tc.RemoteOption(TelnetOptNAWS) {
+		t.Error("NAWS not enabled after WILL")
+	}
+	tc.RequestRemoteOption(TelnetOptNAWS)
+
+	tc.Close()
+	if got, want := <-replies, "\xff\xfd\x1f"; got != want {
+		t.Errorf("sent %q, want just the one DO NAWS %q", got, want)
+	}
+}
+
+func TestTelnetLateDO(t *testing.T) {
+	tc, peer, replies := telnetPipe(t)
+
+	// The echo is restored before the peer gets round to agreeing to the
+	// WILL ECHO; its DO must not turn it back on
+	tc.SuppressEcho(true)
+	if tc.LocalOption(TelnetOptEcho) {
+		t.Error("ECHO enabled before the peer agreed")
+	}
+	tc.SuppressEcho(false)
+
+	go peer.Write([]byte("\xff\xfd\x01$"))
+	readUntil(t, tc, "$")
+	if tc.LocalOption(TelnetOptEcho) {
+		t.Error("ECHO enabled by a DO that arrived after it was restored")
+	}
+
+	go peer.Write([]byte("\xff\xfe\x01$"))
+	readUntil(t, tc, "$")
+	if tc.LocalOption(TelnetOptEcho) {
+		t.Error("ECHO enabled after DONT")
+	}
+
+	// Settled, so a new request goes out again
+	tc.SuppressEcho(true)
+	go peer.Write([]byte("\xff\xfd\x01$"))
+	readUntil(t, tc, "$")
+	if !tc.LocalOption(TelnetOptEcho) {
+		t.Error("ECHO not enabled after DO")
+	}
+
+	tc.Close()
+	// WILL ECHO, WONT ECHO once the DO arrives, then WILL ECHO
+	if got, want := <-replies, "\xff\xfb\x01\xff\xfc\x01\xff\xfb\x01"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestTelnetWrite(t *testing.T) {
+	tc, _, replies := telnetPipe(t)
+
+	if _, err := tc.Write([]byte("a\xffb\nc\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	tc.Close()
+	if got, want := <-replies, "a\xff\xffb\r\nc\r\n"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestTelnetWriteSplitCRLF(t *testing.T) {
+	tc, _, replies := telnetPipe(t)
+
+	for _, chunk := range []string{"a\r", "\nb\n"} {
+		if _, err := tc.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tc.Close()
+	if got, want := <-replies, "a\r\nb\r\n"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
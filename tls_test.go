@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "bbs.crt"), filepath.Join(dir, "bbs.key")
+
+	generated, err := loadOrCreateTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file: %v, %v", info, err)
+	}
+	leaf, err := x509.ParseCertificate(generated.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+
+	// The pair is kept across restarts
+	reloaded, err := loadOrCreateTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reloaded.Certificates[0].Certificate[0], generated.Certificates[0].Certificate[0]) {
+		t.Error("certificate changed on reload")
+	}
+
+	// Half a pair is a mistake to report, not to paper over with a new one
+	os.Remove(certFile)
+	if _, err := loadOrCreateTLSConfig(certFile, keyFile); err == nil {
+		t.Error("loaded a key without its certificate")
+	}
+	if _, err := os.Stat(certFile); !os.IsNotExist(err) {
+		t.Error("generated a certificate for an existing key")
+	}
+}
+
+func TestTLSLogin(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "bbs.crt")
+	s := startTestServer(t, func(config *Config) {
+		config.Listen.TLS = "127.0.0.1:0"
+		config.TLS.CertFile = certFile
+		config.TLS.KeyFile = filepath.Join(dir, "bbs.key")
+	})
+	alice := s.register("alice", "secret")
+
+	pem, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		t.Fatal("generated certificate doesn't parse")
+	}
+	conn, err := tls.Dial("tcp", s.Addr("tls").String(), &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &testClient{t: t, conn: conn, telnet: NewTelnetConn(conn)}
+	c.expect("(L)ogin or (R)egister?")
+	c.send("r")
+	c.expect("Choose a username:")
+	c.send("bob")
+	c.expect("Choose a password:")
+	c.send("secret")
+	c.expect("Welcome, bob")
+	c.expectPrompt()
+
+	c.send("hello over TLS")
+	alice.expect("bob: hello over TLS")
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bbs/internal/storage"
+)
+
+// Longest topic accepted, in characters
+const maxTopicLength = 200
+
+// Columns of the topic shown in the prompt
+const promptTopicWidth = 30
+
+// Topic changes listed by "topic history"
+const topicHistoryLimit = 10
+
+// Most messages pinned in one room
+const maxPins = 10
+
+// prompt returns the command prompt: the current room and its topic.
+func (c *Client) prompt() string {
+	if c.currentRoom.Topic == "" {
+		return fmt.Sprintf("\033[34m[%s]>\033[0m ", c.currentRoom.Name)
+	}
+	return fmt.Sprintf("\033[34m[%s: %s]>\033[0m ", c.currentRoom.Name, truncateText(c.currentRoom.Topic, promptTopicWidth))
+}
+
+// displayTopic shows the current room's topic, if it has one.
+func (c *Client) displayTopic() {
+	if c.currentRoom == nil || c.currentRoom.Topic == "" {
+		return
+	}
+	c.writeWrapped(fmt.Sprintf("\033[36mTopic:\033[0m %s \033[90m(set by %s, %s)\033[0m\n",
+		c.currentRoom.Topic, c.currentRoom.TopicBy, c.currentRoom.TopicAt.Format("2006-01-02 15:04")))
+}
+
+// handleTopic shows, changes or lists the history of the current room's
+// topic. The room's owner and moderators can change it.
+func (c *Client) handleTopic(args []string) {
+	if c.currentRoom == nil {
+		c.write("You are not in a chat room.\n")
+		return
+	}
+	if c.leaveLostRoom() {
+		return
+	}
+	room := c.currentRoom
+
+	switch {
+	case len(args) == 0:
+		if room.Topic == "" {
+			c.write(fmt.Sprintf("No topic set in %s.\n", room.Name))
+			return
+		}
+		c.displayTopic()
+		return
+	case len(args) == 1 && strings.ToLower(args[0]) == "history":
+		c.showTopicHistory()
+		return
+	}
+
+	if !c.ownsRoom(room) && !c.hasRole(storage.RoleModerator) {
+		c.write("Only the room's owner and moderators can change the topic.\n")
+		return
+	}
+	topic := strings.Join(args, " ")
+	if len(args) == 1 && strings.ToLower(args[0]) == "clear" {
+		topic = ""
+	}
+	if len(topic) > maxTopicLength {
+		c.write(fmt.Sprintf("Topic too long (%d characters, limit is %d).\n", len(topic), maxTopicLength))
+		return
+	}
+
+	if err := c.db.SetRoomTopic(room.ID, topic, c.user.ID); err != nil {
+		c.write("Failed to change the topic.\n")
+		return
+	}
+	notice := fmt.Sprintf("\033[36m*** %s changed the topic to: %s ***\033[0m\n", c.user.Username, topic)
+	if topic == "" {
+		notice = fmt.Sprintf("\033[36m*** %s cleared the topic ***\033[0m\n", c.user.Username)
+	}
+	c.server.UpdateRoomTopic(room.ID, topic, c.user.Username, notice)
+}
+
+func (c *Client) showTopicHistory() {
+	changes, err := c.db.GetTopicHistory(c.currentRoom.ID, topicHistoryLimit)
+	if err != nil {
+		c.write("Error loading the topic history.\n")
+		return
+	}
+	if len(changes) == 0 {
+		c.write(fmt.Sprintf("The topic of %s has never been set.\n", c.currentRoom.Name))
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[36mTopics of %s, newest first:\033[0m\n", c.currentRoom.Name))
+	c.write(c.separator("-", 60) + "\n")
+	for _, change := range changes {
+		topic := change.Topic
+		if topic == "" {
+			topic = "(cleared)"
+		}
+		c.writeWrapped(fmt.Sprintf("\033[90m[%s]\033[0m \033[33m%s:\033[0m %s\n",
+			change.SetAt.Format("2006-01-02 15:04"), change.SetBy, topic))
+	}
+	c.write(c.separator("-", 60) + "\n\n")
+}
+
+// UpdateRoomTopic tells everyone in a room about its new topic. Each
+// client picks it up for its prompt before its next command.
+func (s *BBSServer) UpdateRoomTopic(roomID int, topic, setBy, message string) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	change := storage.TopicChange{Topic: topic, SetBy: setBy, SetAt: time.Now()}
+	for client := range s.clients {
+		label, ok := client.roomLabel(roomID)
+		if !ok {
+			continue
+		}
+		client.roomMutex.Lock()
+		if client.topicChanges == nil {
+			client.topicChanges = make(map[int]storage.TopicChange)
+		}
+		client.topicChanges[roomID] = change
+		client.roomMutex.Unlock()
+		client.deliver(label + message)
+	}
+}
+
+// withTopic returns a copy of room with a new topic.
+func withTopic(room *storage.ChatRoom, change storage.TopicChange) *storage.ChatRoom {
+	changed := *room
+	changed.Topic, changed.TopicBy, changed.TopicAt = change.Topic, change.SetBy, change.SetAt
+	return &changed
+}
+
+// displayPins lists the messages pinned in the current room, if any.
+func (c *Client) displayPins() {
+	pins, err := c.db.GetPinnedMessages(c.currentRoom.ID)
+	if err != nil || len(pins) == 0 {
+		return
+	}
+
+	c.write(fmt.Sprintf("\033[35mPinned in %s:\033[0m\n", c.currentRoom.Name))
+	c.write(c.separator("-", 40) + "\n")
+	for _, pin := range pins {
+		c.writeWrapped(fmt.Sprintf("\033[90m#%d [%s]\033[0m \033[33m%s:\033[0m %s%s\n",
+			pin.ID, pin.Timestamp.Format("2006-01-02 15:04"), pin.Username, pin.Content, editedMarker(pin.Message)))
+	}
+	c.write(c.separator("-", 40) + "\n\n")
+}
+
+// showPins lists the current room's pins on request.
+func (c *Client) showPins() {
+	if c.currentRoom == nil {
+		c.write("You are not in a chat room.\n")
+		return
+	}
+	if c.leaveLostRoom() {
+		return
+	}
+	if pins, err := c.db.GetPinnedMessages(c.currentRoom.ID); err == nil && len(pins) == 0 {
+		c.write(fmt.Sprintf("Nothing is pinned in %s.\n", c.currentRoom.Name))
+		return
+	}
+	c.displayPins()
+}
+
+// pinMessage pins a message, given by ID or "last" for the latest one in
+// the current room, to the top of its room.
+func (c *Client) pinMessage(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /pin <id|last>\n")
+		return
+	}
+
+	var msg *storage.Message
+	var err error
+	if strings.ToLower(args[0]) == "last" {
+		if c.currentRoom == nil {
+			c.write("You are not in a chat room.\n")
+			return
+		}
+		var recent []storage.Message
+		if recent, err = c.db.GetRecentMessages(c.currentRoom.ID, 1); err == nil && len(recent) == 0 {
+			err = sql.ErrNoRows
+		} else if err == nil {
+			msg = &recent[0]
+		}
+	} else if id, convErr := strconv.Atoi(args[0]); convErr != nil {
+		c.write("Usage: /pin <id|last>\n")
+		return
+	} else {
+		msg, err = c.db.GetMessage(id)
+	}
+	if err == nil {
+		// Messages in rooms the user can't enter don't exist for them
+		var room *storage.ChatRoom
+		if room, err = c.db.GetMessageRoom(msg.ID); err == nil && !c.canEnter(room) {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		c.write(fmt.Sprintf("No such message '%s'.\n", args[0]))
+		return
+	}
+
+	pins, err := c.db.GetPinnedMessages(msg.RoomID)
+	if err != nil {
+		c.write("Failed to pin the message.\n")
+		return
+	}
+	for _, pin := range pins {
+		if pin.ID == msg.ID {
+			c.write(fmt.Sprintf("Message %d is already pinned.\n", msg.ID))
+			return
+		}
+	}
+	if len(pins) >= maxPins {
+		c.write(fmt.Sprintf("A room can have at most %d pinned messages; unpin one first.\n", maxPins))
+		return
+	}
+
+	if err := c.db.PinMessage(msg.ID, c.user.ID); err != nil {
+		c.write("Failed to pin the message.\n")
+		return
+	}
+	c.write(fmt.Sprintf("\033[32mPinned message %d.\033[0m\n", msg.ID))
+	c.server.BroadcastToRoom(msg.RoomID, fmt.Sprintf("\033[36m*** %s pinned a message from %s; type '/pins' to see it ***\033[0m\n",
+		c.user.Username, msg.Username), c)
+}
+
+// unpinMessage takes a pin down.
+func (c *Client) unpinMessage(args []string) {
+	if len(args) != 1 {
+		c.write("Usage: /unpin <id>\n")
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		c.write("Usage: /unpin <id>\n")
+		return
+	}
+
+	// Pins in rooms the user can't enter don't exist for them
+	room, err := c.db.GetMessageRoom(id)
+	if err == nil && !c.canEnter(room) {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		err = c.db.UnpinMessage(id)
+	}
+	switch err {
+	case nil:
+		c.write(fmt.Sprintf("\033[32mUnpinned message %d.\033[0m\n", id))
+		c.server.BroadcastToRoom(room.ID, fmt.Sprintf("\033[36m*** %s unpinned message %d ***\033[0m\n",
+			c.user.Username, id), c)
+	case sql.ErrNoRows:
+		c.write(fmt.Sprintf("Message %d isn't pinned.\n", id))
+	default:
+		c.write("Failed to unpin the message.\n")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRoomTopic(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	carol.send("join Tech")
+	carol.expect("Joined room: Tech")
+
+	bob.send("/topic Hello")
+	bob.expect("Only the room's owner and moderators can change the topic.")
+	bob.send("/topic")
+	bob.expect("No topic set in General.")
+
+	alice.send("/topic Release day")
+	alice.expect("*** alice changed the topic to: Release day ***")
+	bob.expect("*** alice changed the topic to: Release day ***")
+	carol.expectNothing("Release day")
+	bob.send("/topic")
+	bob.expect("Topic: Release day (set by alice,")
+	bob.expect("[General: Release day]> ")
+
+	carol.send("join General")
+	carol.expect("Joined room: General")
+	carol.expect("Topic: Release day (set by alice,")
+	carol.expect("[General: Release day]> ")
+
+	alice.send("/topic clear")
+	bob.expect("*** alice cleared the topic ***")
+	bob.send("/topic history")
+	out := bob.expect("[General]> ")
+	if !strings.Contains(out, "alice: (cleared)") || !strings.Contains(out, "alice: Release day") {
+		t.Errorf("topic history: %q", out)
+	}
+}
+
+func TestPinnedMessages(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+
+	bob.send("Please read the rules before posting")
+	alice.expect("bob: Please read the rules before posting")
+	bob.send("/pin last")
+	bob.expect("You don't have permission to use 'pin'.")
+
+	general, _ := s.db.GetChatRoom("General")
+	author, _ := s.db.GetUserByName("bob")
+	rules, _ := s.db.GetLastMessage(general.ID, author.ID)
+	alice.send("/pin last")
+	alice.expect(fmt.Sprintf("Pinned message %d.", rules.ID))
+	bob.expect("*** alice pinned a message from bob; type '/pins' to see it ***")
+	alice.send("/pin last")
+	alice.expect(fmt.Sprintf("Message %d is already pinned.", rules.ID))
+
+	// Pins come before the recent history on a first visit
+	bob.send("and be nice")
+	c := s.dial()
+	c.expect("(L)ogin or (R)egister?")
+	c.send("r")
+	c.expect("Choose a username:")
+	c.send("carol")
+	c.expect("Choose a password:")
+	c.send("secret")
+	out := c.expect("Recent messages in General:")
+	if !strings.Contains(out, "Pinned in General:") || !strings.Contains(out, "bob: Please read the rules before posting") {
+		t.Errorf("joining: %q", out)
+	}
+	if strings.Contains(out, "and be nice") {
+		t.Errorf("an unpinned message is listed as pinned: %q", out)
+	}
+
+	// and again on coming back, whether the room was left or subscribed to
+	bob.send("join Tech")
+	bob.expect("Joined room: Tech")
+	bob.send("join General")
+	out = bob.expect("since your last visit")
+	if !strings.Contains(out, "Pinned in General:") {
+		t.Errorf("rejoining: %q", out)
+	}
+	alice.send("/subscribe General")
+	alice.expectPrompt()
+	alice.send("join Tech")
+	alice.expect("Joined room: Tech")
+	alice.send("join General")
+	alice.expect("Now talking in: General")
+	alice.expect("Pinned in General:")
+
+	alice.send(fmt.Sprintf("/unpin %d", rules.ID))
+	alice.expect(fmt.Sprintf("Unpinned message %d.", rules.ID))
+	bob.expect(fmt.Sprintf("*** alice unpinned message %d ***", rules.ID))
+	alice.send("/pins")
+	alice.expect("Nothing is pinned in General.")
+}
+
+func TestPinNeedsRoomAccess(t *testing.T) {
+	s := startTestServer(t, nil)
+	alice := s.register("alice", "secret")
+	bob := s.register("bob", "secret")
+	carol := s.register("carol", "secret")
+	s.privateRoom("Den", "bob")
+
+	alice.send("/role carol moderator")
+	alice.expect("carol is now a moderator.")
+	for _, c := range []*testClient{alice, bob} {
+		c.send("join Den")
+		c.expect("Joined room: Den")
+	}
+	bob.send("secret plans")
+	alice.expect("bob: secret plans")
+
+	den, _ := s.db.GetChatRoom("Den")
+	author, _ := s.db.GetUserByName("bob")
+	plans, _ := s.db.GetLastMessage(den.ID, author.ID)
+	carol.send(fmt.Sprintf("/pin %d", plans.ID))
+	carol.expect(fmt.Sprintf("No such message '%d'.", plans.ID))
+	if pins, _ := s.db.GetPinnedMessages(den.ID); len(pins) != 0 {
+		t.Errorf("pinned from outside the room: %+v", pins)
+	}
+
+	// nor can a pin there be taken down from outside
+	alice.send(fmt.Sprintf("/pin %d", plans.ID))
+	alice.expect(fmt.Sprintf("Pinned message %d.", plans.ID))
+	carol.send(fmt.Sprintf("/unpin %d", plans.ID))
+	carol.expect(fmt.Sprintf("Message %d isn't pinned.", plans.ID))
+	if pins, _ := s.db.GetPinnedMessages(den.ID); len(pins) != 1 {
+		t.Errorf("unpinned from outside the room: %+v", pins)
+	}
+}
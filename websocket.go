@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Browser terminal served at "/"
+//
+//go:embed web/terminal.html
+var terminalPage []byte
+
+// wsControl is a control message exchanged as a text frame. Terminal data
+// travels in binary frames in both directions.
+type wsControl struct {
+	Type    string `json:"type"`
+	Cols    int    `json:"cols,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+// newWebHandler returns the HTTP handler for the browser gateway.
+func (s *BBSServer) newWebHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(terminalPage)
+	})
+	upgrader := &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 4096,
+		CheckOrigin:     s.checkOrigin,
+	}
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		s.handleWebSocket(w, r, upgrader)
+	})
+	return mux
+}
+
+// checkOrigin lets a WebSocket be opened from the BBS's own page, from
+// pages in web.allowed_origins, and by clients other than browsers, which
+// send no Origin. Anything else could be another site's page using a
+// visitor's browser to reach the BBS.
+func (s *BBSServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range s.config.Web.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *BBSServer) handleWebSocket(w http.ResponseWriter, r *http.Request, upgrader *websocket.Upgrader) {
+	if !s.acquireConnection() {
+		http.Error(w, "Sorry, the BBS is full. Please try again later.", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.releaseConnection()
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if ban := s.ipBan(host); ban != nil {
+			http.Error(w, banNotice(ban), http.StatusForbidden)
+			return
+		}
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+
+	term := newWSTerminal(ws)
+	client := NewTerminalClient(term, s.db, s)
+	term.onResize = client.setWindowSize
+	client.Handle()
+}
+
+// wsTerminal adapts a WebSocket to the Terminal interface. The browser
+// page does its own line editing and sends whole lines.
+type wsTerminal struct {
+	ws *websocket.Conn
+
+	// onResize receives "resize" control messages from the page
+	onResize func(width, height int)
+
+	input      *deadlineReader
+	writeMutex sync.Mutex
+	pending    []byte
+}
+
+func newWSTerminal(ws *websocket.Conn) *wsTerminal {
+	return &wsTerminal{
+		ws: ws,
+		input: newDeadlineReader(func() readResult {
+			messageType, data, err := ws.ReadMessage()
+			return readResult{kind: messageType, data: data, err: err}
+		}),
+	}
+}
+
+func (t *wsTerminal) Read(p []byte) (int, error) {
+	for len(t.pending) == 0 {
+		result := t.input.next()
+		if result.err != nil {
+			return 0, result.err
+		}
+
+		data := result.data
+		switch result.kind {
+		case websocket.BinaryMessage:
+			data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+			t.pending = append(t.pending, bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))...)
+		case websocket.TextMessage:
+			var control wsControl
+			if err := json.Unmarshal(data, &control); err != nil {
+				continue
+			}
+			if control.Type == "resize" && t.onResize != nil {
+				t.onResize(control.Cols, control.Rows)
+			}
+		}
+	}
+
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+func (t *wsTerminal) Write(p []byte) (int, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	if err := t.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// SuppressEcho switches the page's input line to a password field.
+func (t *wsTerminal) SuppressEcho(suppress bool) {
+	data, _ := json.Marshal(wsControl{Type: "echo", Enabled: !suppress})
+
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+	t.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func (t *wsTerminal) Close() error {
+	t.input.close()
+	return t.ws.Close()
+}
+
+func (t *wsTerminal) LocalAddr() net.Addr {
+	return t.ws.LocalAddr()
+}
+
+func (t *wsTerminal) RemoteAddr() net.Addr {
+	return t.ws.RemoteAddr()
+}
+
+// A read deadline on the WebSocket itself would break it when it passed,
+// so they're kept by t.input instead
+func (t *wsTerminal) SetDeadline(deadline time.Time) error {
+	t.input.setDeadline(deadline)
+	return t.ws.SetWriteDeadline(deadline)
+}
+
+func (t *wsTerminal) SetReadDeadline(deadline time.Time) error {
+	t.input.setDeadline(deadline)
+	return nil
+}
+
+func (t *wsTerminal) SetWriteDeadline(deadline time.Time) error {
+	return t.ws.SetWriteDeadline(deadline)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"bbs/internal/storage"
+)
+
+// startWebServer starts a test server that also serves the browser
+// gateway. configure, if not nil, may adjust the config further.
+func startWebServer(t *testing.T, configure func(*Config)) *testServer {
+	t.Helper()
+	return startTestServer(t, func(config *Config) {
+		config.Listen.Web = "127.0.0.1:0"
+		if configure != nil {
+			configure(config)
+		}
+	})
+}
+
+// dialWeb opens the gateway's WebSocket as a page from origin would, or
+// as a non-browser client if origin is empty. Terminal data is bridged
+// onto a net.Pipe for the usual testClient. The response is returned for
+// a refused handshake.
+func (s *testServer) dialWeb(origin string) (*testClient, *http.Response, error) {
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	ws, resp, err := websocket.DefaultDialer.Dial("ws://"+s.Addr("web").String()+"/ws", header)
+	if err != nil {
+		return nil, resp, err
+	}
+	s.t.Cleanup(func() { ws.Close() })
+
+	local, remote := net.Pipe()
+	s.t.Cleanup(func() { local.Close() })
+	go func() {
+		defer remote.Close()
+		for {
+			kind, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			// Text frames are control messages for the page
+			if kind == websocket.BinaryMessage {
+				if _, err := remote.Write(data); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := remote.Read(buf)
+			if err != nil {
+				return
+			}
+			if err := ws.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	return &testClient{t: s.t, conn: local, telnet: NewTelnetConn(local)}, resp, nil
+}
+
+// expectRefused checks that a handshake was turned down with status and
+// a body mentioning notice.
+func expectRefused(t *testing.T, resp *http.Response, err error, status int, notice string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("WebSocket opened, want status %d", status)
+	}
+	if resp == nil || resp.StatusCode != status {
+		t.Fatalf("refused with %v, want status %d", err, status)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), notice) {
+		t.Errorf("refusal %q doesn't say %q", body, notice)
+	}
+}
+
+func TestWebSocketLogin(t *testing.T) {
+	s := startWebServer(t, nil)
+	alice := s.register("alice", "secret")
+	s.register("bob", "secret")
+
+	c, _, err := s.dialWeb("http://" + s.Addr("web").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.expect("(L)ogin or (R)egister?")
+	c.send("l")
+	c.expect("Username:")
+	c.send("bob")
+	c.expect("Password:")
+	c.send("secret")
+	c.expect("Welcome back, bob")
+	c.expectPrompt()
+
+	c.send("hello from the browser")
+	alice.expect("bob: hello from the browser")
+	c.send("quit")
+	c.expect("Goodbye!")
+	c.expectClosed()
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	s := startWebServer(t, func(config *Config) {
+		config.Web.AllowedOrigins = []string{"https://bbs.example.com"}
+	})
+
+	for _, origin := range []string{"", "http://" + s.Addr("web").String(), "https://bbs.example.com", "https://BBS.example.com"} {
+		c, _, err := s.dialWeb(origin)
+		if err != nil {
+			t.Errorf("origin %q: %v", origin, err)
+			continue
+		}
+		c.expect("(L)ogin or (R)egister?")
+	}
+
+	for _, origin := range []string{"https://evil.example.com", "http://bbs.example.com", "null"} {
+		_, resp, err := s.dialWeb(origin)
+		expectRefused(t, resp, err, http.StatusForbidden, "")
+	}
+}
+
+func TestWebSocketRefused(t *testing.T) {
+	t.Run("full", func(t *testing.T) {
+		s := startWebServer(t, func(config *Config) {
+			config.Limits.MaxConnections = 1
+		})
+		c, _, err := s.dialWeb("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.expect("(L)ogin or (R)egister?")
+
+		_, resp, err := s.dialWeb("")
+		expectRefused(t, resp, err, http.StatusServiceUnavailable, "Sorry, the BBS is full.")
+	})
+
+	t.Run("banned", func(t *testing.T) {
+		s := startWebServer(t, nil)
+		s.register("alice", "secret")
+		user, _ := s.db.GetUserByName("alice")
+		if _, err := s.db.AddSanction(storage.Sanction{Kind: storage.SanctionBan, IP: "127.0.0.1",
+			Reason: "proxy abuse", IssuedBy: user.ID}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, resp, err := s.dialWeb("")
+		expectRefused(t, resp, err, http.StatusForbidden, "You are banned from this BBS until further notice: proxy abuse.")
+	})
+}